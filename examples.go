@@ -21,7 +21,7 @@ func sTKPushExample() (err error) {
 	express := &mpesa.Express{
 		ShortCode:   "174379",
 		Password:    "LNM Password",
-		Amount:      1,
+		Amount:      mpesa.KES(1),
 		PhoneNumber: "0712345678",
 		CallBackURL: "https://callback.com",
 	}
@@ -56,7 +56,7 @@ func b2CExample() (err error) {
 		InitiatorUserName: "testapi115",
 		InitiatorPassword: "Safaricom007@",
 		PhoneNumber:       "254708374149",
-		Amount:            100,
+		Amount:            mpesa.KES(100),
 		ResultCallBackURL: "https://callback.com/results",
 	}
 	res, err := mpesaService.B2C(b2c)
@@ -67,6 +67,29 @@ func b2CExample() (err error) {
 	return
 }
 
+func b2BExample() (err error) {
+	mpesaService, err := mpesa.NewMpesa(MpesaConfig)
+	if err != nil {
+		return
+	}
+	b2b := &mpesa.B2B{
+		CommandID:         mpesa.BusinessPayBill,
+		ShortCode:         "600000",
+		ReceiverShortCode: "600001",
+		AccountReference:  "INV-0001",
+		InitiatorUserName: "testapi",
+		InitiatorPassword: "Safaricom999!*!",
+		Amount:            mpesa.KES(100),
+		ResultCallBackURL: "https://callback.com/results",
+	}
+	res, err := mpesaService.B2B(b2b)
+	if err != nil {
+		return
+	}
+	fmt.Println(res.ResponseDescription)
+	return
+}
+
 func balanceQueryExample() (err error) {
 	mpesaService, err := mpesa.NewMpesa(MpesaConfig)
 	if err != nil {
@@ -117,7 +140,7 @@ func reversalExample() (err error) {
 		InitiatorPassword: "Safaricom007@",
 		ResultCallBackURL: "https://callback.com/",
 		TransactionID:     "LKXXXX1234",
-		Amount:            1.00,
+		Amount:            mpesa.KES(1),
 	}
 	res, err := mpesaService.Reverse(reversal)
 	if err != nil {
@@ -153,7 +176,7 @@ func c2BSimulateExample() (err error) {
 		return
 	}
 	c2bSimulate := &mpesa.C2BSimulate{
-		Amount:    100,
+		Amount:    mpesa.KES(100),
 		ShortCode: "123456",
 		//phone number
 		Msisdn: "254712345678",
@@ -0,0 +1,152 @@
+package mpesa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//maxAmountDigits bounds the shillings of an Amount so its cents fit in an int64
+const maxAmountDigits = 15
+
+//Amount is an exact amount of KES, stored in cents so it never loses precision
+//like float32 amounts do. The zero value is KES 0.
+type Amount struct {
+	cents int64
+}
+
+//KES returns an Amount of shillings
+func KES(shillings int64) Amount {
+	return Amount{cents: shillings * 100}
+}
+
+//Cents returns an Amount of cents e.g. Cents(150) is KES 1.50
+func Cents(cents int64) Amount {
+	return Amount{cents: cents}
+}
+
+//ParseAmount parses a decimal amount e.g. "1", "1.5", "346237.00",
+//amounts with a fraction of a cent are rejected
+func ParseAmount(value string) (a Amount, err error) {
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" || len(whole) > maxAmountDigits || !isDigits(whole) || !isDigits(fraction) {
+		err = fmt.Errorf("Invalid amount %q", value)
+		return
+	}
+	if len(strings.TrimRight(fraction, "0")) > 2 {
+		err = fmt.Errorf("Invalid amount %q, less than a cent", value)
+		return
+	}
+	fraction = (fraction + "00")[:2]
+	cents, _ := strconv.ParseInt(whole+fraction, 10, 64)
+	if negative {
+		cents = -cents
+	}
+	a = Amount{cents: cents}
+	return
+}
+
+//isDigits is true when s only has ascii digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//Cents returns the amount in cents
+func (a Amount) Cents() int64 {
+	return a.cents
+}
+
+//IsZero is true for KES 0
+func (a Amount) IsZero() bool {
+	return a.cents == 0
+}
+
+//IsWhole is true when the amount has no cents, daraja only accepts whole
+//amounts for express, b2c and b2b payments
+func (a Amount) IsWhole() bool {
+	return a.cents%100 == 0
+}
+
+//Sign returns -1, 0 or 1 for negative, zero and positive amounts
+func (a Amount) Sign() int {
+	switch {
+	case a.cents < 0:
+		return -1
+	case a.cents > 0:
+		return 1
+	}
+	return 0
+}
+
+//Add returns a + b
+func (a Amount) Add(b Amount) Amount {
+	return Amount{cents: a.cents + b.cents}
+}
+
+//Sub returns a - b
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{cents: a.cents - b.cents}
+}
+
+//String formats the amount the way daraja expects it, shillings only for
+//whole amounts e.g. "100", otherwise with the cents e.g. "100.50"
+func (a Amount) String() string {
+	cents := a.cents
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	if cents%100 == 0 {
+		return fmt.Sprintf("%s%d", sign, cents/100)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+//MarshalJSON encodes the amount as a json number formatted by String
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+//UnmarshalJSON decodes a json number or a numeric string as daraja sends both,
+//null and "" leave the amount unchanged
+func (a *Amount) UnmarshalJSON(data []byte) (err error) {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return
+	}
+	value := string(data)
+	if strings.HasPrefix(value, `"`) {
+		err = json.Unmarshal(data, &value)
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+	}
+	*a, err = ParseAmount(value)
+	return
+}
+
+//amountValue parses a json value decoded with UseNumber, zero when missing
+func amountValue(value interface{}) (a Amount, err error) {
+	number := jsonNumber(value)
+	if number == "" {
+		return
+	}
+	return ParseAmount(number.String())
+}
@@ -0,0 +1,87 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value string
+		cents int64
+		str   string
+	}{
+		{"1", 100, "1"},
+		{"1.5", 150, "1.50"},
+		{"346237.00", 34623700, "346237"},
+		{"0.05", 5, "0.05"},
+		{"1000000", 100000000, "1000000"},
+		{"-100.50", -10050, "-100.50"},
+		{" 10.100 ", 1010, "10.10"},
+	}
+	for _, test := range tests {
+		a, err := ParseAmount(test.value)
+		if err != nil {
+			t.Fatalf("%q: %v", test.value, err)
+		}
+		if a.Cents() != test.cents || a.String() != test.str {
+			t.Fatalf("%q: expected %d cents formatted %q, got %d formatted %q", test.value, test.cents, test.str, a.Cents(), a)
+		}
+	}
+	for _, value := range []string{"", ".5", "1.005", "0.001", "1e6", "1,000", "--1", "1.2.3", "1234567890123456"} {
+		if _, err := ParseAmount(value); err == nil {
+			t.Fatalf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	for _, a := range []Amount{KES(1000000), Cents(10050), Cents(-5), KES(0)} {
+		data, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != a.String() {
+			t.Fatalf("expected a json number %s, got %s", a, data)
+		}
+		var decoded Amount
+		if err = json.Unmarshal(data, &decoded); err != nil || decoded != a {
+			t.Fatalf("expected %s back, got %s, %v", a, decoded, err)
+		}
+	}
+	var v struct {
+		Amount  Amount
+		Balance Amount
+		Charge  Amount
+	}
+	v.Balance, v.Charge = KES(5), KES(7)
+	err := json.Unmarshal([]byte(`{"Amount":"100.50","Balance":null,"Charge":""}`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Amount != Cents(10050) || v.Balance != KES(5) || v.Charge != KES(7) {
+		t.Fatalf("expected null and \"\" to be ignored, got %+v", v)
+	}
+	if err = json.Unmarshal([]byte(`"0.001"`), &v.Amount); err == nil {
+		t.Fatal("expected an error for less than a cent")
+	}
+}
+
+func TestAmountValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		a     Amount
+	}{
+		{json.Number("1000000"), KES(1000000)},
+		{float64(1000000), KES(1000000)},
+		{float64(2000000.5), Cents(200000050)},
+		{"346237.00", KES(346237)},
+		{nil, Amount{}},
+	}
+	for _, test := range tests {
+		a, err := amountValue(test.value)
+		if err != nil || a != test.a {
+			t.Fatalf("%#v: expected %s, got %s, %v", test.value, test.a, a, err)
+		}
+	}
+}
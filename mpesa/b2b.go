@@ -0,0 +1,212 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+//B2BAPI service interface
+type B2BAPI interface {
+	B2B(b2b *B2B) (apiRes *APIRes, err error)
+	B2BCtx(ctx context.Context, b2b *B2B) (apiRes *APIRes, err error)
+	ParseB2BResult(b2bResultRes io.Reader) (b2bResult *B2BResult, err error)
+}
+
+//B2B model
+type B2B struct {
+	InitiatorUserName string
+	InitiatorPassword string
+	//ShortCode sending the funds
+	ShortCode string
+	//optional defaults to OrganizationIdentifierType
+	SenderIdentifierType string
+	//ReceiverShortCode paybill or till receiving the funds
+	ReceiverShortCode string
+	//optional defaults to TillNumberIdentifierType for BusinessBuyGoods
+	//and OrganizationIdentifierType otherwise
+	RecieverIdentifierType string
+	Amount                 Amount
+	//optional defaults to BusinessPayBill
+	CommandID string
+	//AccountReference account number at the receiver, required for BusinessPayBill
+	AccountReference string
+	//Requester optional phone number of the customer the payment is made for
+	Requester         string
+	ResultCallBackURL string
+	//optional defaults to ResultURL
+	TimeOutCallBackURL string
+	//optional defaults to ""
+	Remarks         string
+	EncryptPassword bool
+}
+
+//OK validates B2B
+func (m *B2B) OK() (err error) {
+	digitMatch := regexp.MustCompile(`^[0-9]+$`)
+	//shortcodes
+	if !digitMatch.MatchString(m.ShortCode) {
+		err = fmt.Errorf("ShortCode must be a valid numeric string")
+		return
+	}
+	if !digitMatch.MatchString(m.ReceiverShortCode) {
+		err = fmt.Errorf("ReceiverShortCode must be a valid numeric string")
+		return
+	}
+	//initiator username
+	if m.InitiatorUserName == "" {
+		err = fmt.Errorf("Must provide initiator username")
+		return
+	}
+	//initiator password
+	if m.InitiatorPassword == "" {
+		err = fmt.Errorf("Must provide initiator password")
+		return
+	}
+	//commandId
+	switch m.CommandID {
+	case BusinessPayBill, BusinessBuyGoods, DisburseFundsToBusiness, BusinessToBusinessTransfer, MerchantToMerchantTransfer:
+		break
+	case "":
+		m.CommandID = BusinessPayBill
+		break
+	default:
+		err = fmt.Errorf("Invalid CommandID")
+		return
+	}
+	if m.CommandID == BusinessPayBill && m.AccountReference == "" {
+		err = fmt.Errorf("Must provide AccountReference for BusinessPayBill")
+		return
+	}
+	//IdentiferTypes
+	switch m.SenderIdentifierType {
+	case TillNumberIdentifierType, OrganizationIdentifierType:
+		break
+	case "":
+		m.SenderIdentifierType = OrganizationIdentifierType
+		break
+	default:
+		err = fmt.Errorf("Invalid sender identifier type")
+		return
+	}
+	switch m.RecieverIdentifierType {
+	case TillNumberIdentifierType, OrganizationIdentifierType:
+		break
+	case "":
+		m.RecieverIdentifierType = OrganizationIdentifierType
+		if m.CommandID == BusinessBuyGoods {
+			m.RecieverIdentifierType = TillNumberIdentifierType
+		}
+		break
+	default:
+		err = fmt.Errorf("Invalid receiver identifier type")
+		return
+	}
+	//requester
+	if m.Requester != "" {
+		phoneNumber, errX := FormatPhoneNumber(m.Requester, "E164")
+		if errX != nil {
+			err = errX
+			return
+		}
+		//slice +
+		m.Requester = phoneNumber[1:]
+	}
+	if m.Amount.Sign() <= 0 {
+		err = fmt.Errorf("Amount must be > 0")
+		return
+	}
+	if !m.Amount.IsWhole() {
+		err = fmt.Errorf("Amount must be a whole number of shillings")
+		return
+	}
+	if m.ResultCallBackURL == "" {
+		err = fmt.Errorf("Must provide a result callback url")
+		return
+	}
+	if m.TimeOutCallBackURL == "" {
+		m.TimeOutCallBackURL = m.ResultCallBackURL
+	}
+	if m.Remarks == "" {
+		m.Remarks = "empty remarks"
+	}
+	return
+}
+
+//B2BPayload api payload
+type B2BPayload struct {
+	//Initiator is the credential/username used to authenticate the transaction request.
+	Initiator string `json:"Initiator"`
+	//SecurityCredential is the Base64 encoded string of the
+	//B2B short code and password, which is encrypted using
+	//M-Pesa public key and validates the transaction on M-Pesa Core system.
+	SecurityCredential string `json:"SecurityCredential"`
+	//CommandID	Unique command for each transaction type
+	//e.g. BusinessPayBill, BusinessBuyGoods
+	CommandID string `json:"CommandID"`
+	//SenderIdentifierType Type of organization sending the transaction.
+	SenderIdentifierType string `json:"SenderIdentifierType"`
+	//RecieverIdentifierType Type of organization receiving the transaction.
+	RecieverIdentifierType string `json:"RecieverIdentifierType"`
+	//Amount The amount being transacted
+	Amount string `json:"Amount"`
+	//PartyA Organization’s short code initiating the transaction.
+	PartyA string `json:"PartyA"`
+	//PartyB Organization’s short code receiving the funds being transacted.
+	PartyB string `json:"PartyB"`
+	//AccountReference Account Reference mandatory for “BusinessPaybill” CommandID.
+	AccountReference string `json:"AccountReference"`
+	//Requester Phone number of the customer the payment is made for, optional.
+	Requester string `json:"Requester,omitempty"`
+	//Remarks Comments that are sent along with the transaction.
+	Remarks string `json:"Remarks"`
+	//QueueTimeOutURL The path that stores information of time out transactions.
+	QueueTimeOutURL string `json:"QueueTimeOutURL"`
+	//ResultURL	The path that receives results from M-Pesa.
+	ResultURL string `json:"ResultURL"`
+}
+
+//B2B sends a b2b request to daraja
+func (s *Mpesa) B2B(b2b *B2B) (apiRes *APIRes, err error) {
+	return s.B2BCtx(context.Background(), b2b)
+}
+
+//B2BCtx is B2B bound to ctx
+func (s *Mpesa) B2BCtx(ctx context.Context, b2b *B2B) (apiRes *APIRes, err error) {
+	err = b2b.OK()
+	if err != nil {
+		err = notSent(err)
+		return
+	}
+	//encrypt password
+	var securityCredential string
+	if b2b.EncryptPassword {
+		securityCredential, err = EncryptPassword(b2b.InitiatorPassword, s.Config.Environment)
+		if err != nil {
+			err = notSent(err)
+			return
+		}
+	} else {
+		securityCredential = b2b.InitiatorPassword
+	}
+
+	payload := &B2BPayload{
+		Initiator:              b2b.InitiatorUserName,
+		SecurityCredential:     securityCredential,
+		CommandID:              b2b.CommandID,
+		SenderIdentifierType:   b2b.SenderIdentifierType,
+		RecieverIdentifierType: b2b.RecieverIdentifierType,
+		Amount:                 b2b.Amount.String(),
+		PartyA:                 b2b.ShortCode,
+		PartyB:                 b2b.ReceiverShortCode,
+		AccountReference:       b2b.AccountReference,
+		Requester:              b2b.Requester,
+		Remarks:                b2b.Remarks,
+		QueueTimeOutURL:        b2b.TimeOutCallBackURL,
+		ResultURL:              b2b.ResultCallBackURL,
+	}
+	endpoint := "/mpesa/b2b/v1/paymentrequest"
+	apiRes, err = s.APIResCtx(ctx, endpoint, payload)
+	return
+}
@@ -0,0 +1,116 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+//basicAmountMatch extracts the amount of balances sent as
+//"{Amount={BasicAmount=46713.00, MinimumAmount=4671300, CurrencyCode=KES}}"
+var basicAmountMatch = regexp.MustCompile(`BasicAmount=(-?[0-9.]+)`)
+
+//B2BResult is the typed result of a b2b payment, the fields other than
+//Result are only set for successful payments
+type B2BResult struct {
+	Result
+	Amount   Amount
+	Currency string
+	//ReceiverPartyPublicName e.g. "000000 - Org Name"
+	ReceiverPartyPublicName string
+	//TransCompletedTime in the Africa/Nairobi timezone
+	TransCompletedTime time.Time
+	DebitPartyCharges  string
+	//DebitAccountBalance & InitiatorAccountCurrentBalance are the BasicAmount of the balances
+	DebitAccountBalance            Amount
+	InitiatorAccountCurrentBalance Amount
+	//DebitPartyAffectedAccountBalance as sent e.g. "Working Account|KES|346568.83|6186.83|340382.00|0.00"
+	DebitPartyAffectedAccountBalance string
+	//BillReferenceNumber the AccountReference of the payment, from ReferenceData
+	BillReferenceNumber string
+}
+
+//basicAmount returns the BasicAmount of the balance parameter key, zero when missing
+func (r *Result) basicAmount(key string) (a Amount, err error) {
+	match := basicAmountMatch.FindStringSubmatch(r.paramString(key))
+	if match == nil {
+		return
+	}
+	a, err = ParseAmount(match[1])
+	if err != nil {
+		err = fmt.Errorf("%s: %v", key, err)
+	}
+	return
+}
+
+//newB2BResult converts the parameters of result
+func newB2BResult(result *Result) (b2bResult *B2BResult, err error) {
+	completed, err := result.paramTime("TransCompletedTime", darajaTimeLayout)
+	if err != nil {
+		return
+	}
+	res := &B2BResult{
+		Result:                           *result,
+		Currency:                         result.paramString("Currency"),
+		ReceiverPartyPublicName:          result.paramString("ReceiverPartyPublicName"),
+		TransCompletedTime:               completed,
+		DebitPartyCharges:                result.paramString("DebitPartyCharges"),
+		DebitPartyAffectedAccountBalance: result.paramString("DebitPartyAffectedAccountBalance"),
+	}
+	res.Amount, err = result.paramAmount("Amount")
+	if err != nil {
+		return
+	}
+	res.DebitAccountBalance, err = result.basicAmount("DebitAccountBalance")
+	if err != nil {
+		return
+	}
+	res.InitiatorAccountCurrentBalance, err = result.basicAmount("InitiatorAccountCurrentBalance")
+	if err != nil {
+		return
+	}
+	if ref, ok := result.Reference("BillReferenceNumber"); ok && ref != nil {
+		res.BillReferenceNumber = fmt.Sprint(ref)
+	}
+	b2bResult = res
+	return
+}
+
+//ParseB2BResult parses the payload posted to the ResultCallBackURL of a b2b payment
+func (s *Mpesa) ParseB2BResult(b2bResultRes io.Reader) (b2bResult *B2BResult, err error) {
+	result, err := parseResult(b2bResultRes)
+	if err != nil {
+		return
+	}
+	return newB2BResult(result)
+}
+
+//B2BResultFunc handles a parsed b2b result, returning an error makes
+//the handler respond with a 500 status
+type B2BResultFunc func(ctx context.Context, res *B2BResult) error
+
+//B2BResultHandler is an http.Handler for the b2b ResultCallBackURL,
+//successful payments are passed to OnSuccess and all other outcomes to OnFailure.
+//Use a QueueTimeoutHandler for the TimeOutCallBackURL.
+type B2BResultHandler struct {
+	OnSuccess B2BResultFunc
+	OnFailure B2BResultFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewB2BResultHandler returns *B2BResultHandler, either hook may be nil
+func NewB2BResultHandler(onSuccess, onFailure B2BResultFunc) *B2BResultHandler {
+	return &B2BResultHandler{
+		OnSuccess: onSuccess,
+		OnFailure: onFailure,
+	}
+}
+
+//ServeHTTP parses the result, dispatches it and acknowledges it to daraja
+func (h *B2BResultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, r, h.MaxBodyBytes, newB2BResult, h.OnSuccess, h.OnFailure)
+}
@@ -0,0 +1,48 @@
+package mpesa_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+func TestParseB2BResultBasicAmount(t *testing.T) {
+	s := &mpesa.Mpesa{}
+	tests := []struct {
+		name    string
+		balance string
+		amount  mpesa.Amount
+	}{
+		{"as sent", "{Amount={BasicAmount=46713.00, MinimumAmount=4671300, CurrencyCode=KES}}", mpesa.KES(46713)},
+		{"cents", "{Amount={BasicAmount=346568.83, MinimumAmount=34656883, CurrencyCode=KES}}", mpesa.Cents(34656883)},
+		{"negative", "{Amount={BasicAmount=-12.50, MinimumAmount=-1250, CurrencyCode=KES}}", mpesa.Cents(-1250)},
+		{"without BasicAmount", "{Amount={MinimumAmount=4671300, CurrencyCode=KES}}", mpesa.Amount{}},
+		{"empty", "", mpesa.Amount{}},
+	}
+	for _, test := range tests {
+		params := `[{"Key":"Amount","Value":10.00},{"Key":"TransCompletedTime","Value":20191219102115},
+		{"Key":"DebitAccountBalance","Value":"` + test.balance + `"},{"Key":"InitiatorAccountCurrentBalance","Value":"` + test.balance + `"}]`
+		res, err := s.ParseB2BResult(strings.NewReader(resultBody(params, `{"Key":"BillReferenceNumber","Value":19008}`)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if res.DebitAccountBalance != test.amount || res.InitiatorAccountCurrentBalance != test.amount {
+			t.Fatalf("%s: expected %v, got %v and %v", test.name, test.amount, res.DebitAccountBalance, res.InitiatorAccountCurrentBalance)
+		}
+		if res.Amount != mpesa.KES(10) || res.BillReferenceNumber != "19008" {
+			t.Fatalf("%s: unexpected result %+v", test.name, res)
+		}
+	}
+
+	for _, balance := range []string{
+		"{Amount={BasicAmount=1.2.3, CurrencyCode=KES}}",
+		"{Amount={BasicAmount=10.005, CurrencyCode=KES}}",
+		"{Amount={BasicAmount=., CurrencyCode=KES}}",
+	} {
+		params := `{"Key":"DebitAccountBalance","Value":"` + balance + `"}`
+		if _, err := s.ParseB2BResult(strings.NewReader(resultBody(params, `[]`))); err == nil {
+			t.Fatalf("%s: expected an error", balance)
+		}
+	}
+}
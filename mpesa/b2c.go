@@ -1,14 +1,18 @@
 package mpesa
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"regexp"
-	"strconv"
+	"strings"
 )
 
 //B2CAPI service intercface
 type B2CAPI interface {
 	B2C(b2c *B2C) (apiRes *APIRes, err error)
+	B2CCtx(ctx context.Context, b2c *B2C) (apiRes *APIRes, err error)
+	ParseB2CResult(b2cResultRes io.Reader) (b2cResult *B2CResult, err error)
 }
 
 //B2C model
@@ -17,7 +21,7 @@ type B2C struct {
 	InitiatorPassword string
 	ShortCode         string
 	PhoneNumber       string
-	Amount            int
+	Amount            Amount
 	//optional defaults to BusinessPayment
 	CommandID         string
 	ResultCallBackURL string
@@ -25,9 +29,18 @@ type B2C struct {
 	TimeOutCallBackURL string
 	//optional defaults to ""
 	Remarks string
+	//Occasion optional, echoed back in the result
+	Occasion string
+	//OriginatorConversationID optional unique id of the payment e.g. your payout id,
+	//when set the payment is sent to the v3 endpoint which rejects duplicates and
+	//echoes the id back in the response and result
+	OriginatorConversationID string
 	EncryptPassword bool
 }
 
+//maxB2CFieldLength is the longest Occasion & OriginatorConversationID accepted by daraja
+const maxB2CFieldLength = 100
+
 //OK validates B2C
 func (m *B2C) OK() (err error) {
 	//shortcode
@@ -70,10 +83,14 @@ func (m *B2C) OK() (err error) {
 		err = fmt.Errorf("Invalid CommandID")
 		return
 	}
-	if m.Amount <= 0 {
+	if m.Amount.Sign() <= 0 {
 		err = fmt.Errorf("Amount must be > 0")
 		return
 	}
+	if !m.Amount.IsWhole() {
+		err = fmt.Errorf("Amount must be a whole number of shillings")
+		return
+	}
 	if m.ResultCallBackURL == "" {
 		err = fmt.Errorf("Must provide a result callback url")
 		return
@@ -84,6 +101,18 @@ func (m *B2C) OK() (err error) {
 	if m.Remarks == "" {
 		m.Remarks = "empty remarks"
 	}
+	if len(m.Occasion) > maxB2CFieldLength {
+		err = fmt.Errorf("Occasion must be at most %d characters", maxB2CFieldLength)
+		return
+	}
+	if len(m.OriginatorConversationID) > maxB2CFieldLength {
+		err = fmt.Errorf("OriginatorConversationID must be at most %d characters", maxB2CFieldLength)
+		return
+	}
+	if strings.TrimSpace(m.OriginatorConversationID) != m.OriginatorConversationID {
+		err = fmt.Errorf("OriginatorConversationID must not have leading or trailing spaces")
+		return
+	}
 	return
 }
 
@@ -111,13 +140,22 @@ type B2CPayload struct {
 	QueueTimeOutURL string `json:"QueueTimeOutURL"`
 	//ResultURL	The end-point that receives the response of the transaction
 	ResultURL string `json:"ResultURL"`
+	//Occassion Optional, daraja's spelling.
 	Occassion string `json:"Occassion"`
+	//OriginatorConversationID Unique id of the payment, v3 endpoint only.
+	OriginatorConversationID string `json:"OriginatorConversationID,omitempty"`
 }
 
 //B2C sends a b2c request to daraja
 func (s *Mpesa) B2C(b2c *B2C) (apiRes *APIRes, err error) {
+	return s.B2CCtx(context.Background(), b2c)
+}
+
+//B2CCtx is B2C bound to ctx
+func (s *Mpesa) B2CCtx(ctx context.Context, b2c *B2C) (apiRes *APIRes, err error) {
 	err = b2c.OK()
 	if err != nil {
+		err = notSent(err)
 		return
 	}
 	//encrypt password
@@ -125,6 +163,7 @@ func (s *Mpesa) B2C(b2c *B2C) (apiRes *APIRes, err error) {
 	if(b2c.EncryptPassword){
 		securityCredential, err = EncryptPassword(b2c.InitiatorPassword, s.Config.Environment)
 		if err != nil {
+			err = notSent(err)
 			return
 		}
 	}else{
@@ -135,14 +174,19 @@ func (s *Mpesa) B2C(b2c *B2C) (apiRes *APIRes, err error) {
 		InitiatorName:      b2c.InitiatorUserName,
 		SecurityCredential: securityCredential,
 		CommandID:          b2c.CommandID,
-		Amount:             strconv.Itoa(b2c.Amount),
+		Amount:             b2c.Amount.String(),
 		PartyA:             b2c.ShortCode,
 		PartyB:             b2c.PhoneNumber,
 		Remarks:            b2c.Remarks,
 		QueueTimeOutURL:    b2c.TimeOutCallBackURL,
 		ResultURL:          b2c.ResultCallBackURL,
+		Occassion:          b2c.Occasion,
 	}
 	endpoint := "/mpesa/b2c/v1/paymentrequest"
-	apiRes, err = s.APIRes(endpoint, payload)
+	if b2c.OriginatorConversationID != "" {
+		payload.OriginatorConversationID = b2c.OriginatorConversationID
+		endpoint = "/mpesa/b2c/v3/paymentrequest"
+	}
+	apiRes, err = s.APIResCtx(ctx, endpoint, payload)
 	return
 }
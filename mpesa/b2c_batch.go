@@ -0,0 +1,361 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultBatchConcurrency is how many b2c requests a B2CBatch sends at once
+const DefaultBatchConcurrency = 4
+
+//DefaultBatchRate is how many b2c requests per second a B2CBatch sends at most
+const DefaultBatchRate = 5
+
+//Payee is a line of a b2c batch
+type Payee struct {
+	//ID unique id of the line e.g. your payout id, sent as the OriginatorConversationID
+	//so the payment is never made twice even when the batch is submitted again
+	ID          string
+	PhoneNumber string
+	Amount      Amount
+	//Remarks & Occasion optional override those of the batch template
+	Remarks  string
+	Occasion string
+}
+
+//PayoutStatus is the state of a batch line
+type PayoutStatus int
+
+const (
+	//PayoutPending the payment was accepted (or its outcome is unknown) and its result has not arrived yet
+	PayoutPending PayoutStatus = iota
+	//PayoutPaid the result reported a successful payment
+	PayoutPaid
+	//PayoutFailed the payment was certainly rejected or its result reported a failure
+	PayoutFailed
+	//PayoutDuplicate daraja already received a payment with the same ID, e.g. from a previous
+	//submission, that payment may or may not have succeeded
+	PayoutDuplicate
+)
+
+//String returns the name of the status
+func (s PayoutStatus) String() string {
+	switch s {
+	case PayoutPending:
+		return "pending"
+	case PayoutPaid:
+		return "paid"
+	case PayoutFailed:
+		return "failed"
+	case PayoutDuplicate:
+		return "duplicate"
+	}
+	return "unknown"
+}
+
+//PayoutLine is the state of a payee in a batch
+type PayoutLine struct {
+	Payee  Payee
+	Status PayoutStatus
+	//ConversationID assigned by daraja when the payment was accepted
+	ConversationID     string
+	TransactionReceipt string
+	//ResultCode & ResultDesc of the result callback
+	ResultCode string
+	ResultDesc string
+	//Err why the payment was not accepted, or why its outcome is unknown
+	Err error
+}
+
+//BatchSummary counts the lines of a batch by status
+type BatchSummary struct {
+	Total     int
+	Paid      int
+	Failed    int
+	Pending   int
+	Duplicate int
+	//PaidAmount is the sum of the amounts of paid lines
+	PaidAmount Amount
+}
+
+//BatchReport is a snapshot of a batch, lines are in submission order
+type BatchReport struct {
+	Lines   []PayoutLine
+	Summary BatchSummary
+}
+
+//LineError is an invalid payee
+type LineError struct {
+	//Line index of the payee in the submitted list
+	Line int
+	ID   string
+	Err  error
+}
+
+//BatchValidationError lists every invalid payee of a batch, nothing is sent when it is returned
+type BatchValidationError struct {
+	Lines []LineError
+}
+
+//Error returns error message
+func (e *BatchValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Lines))
+	for _, l := range e.Lines {
+		msgs = append(msgs, fmt.Sprintf("line %d (%s): %s", l.Line, l.ID, l.Err))
+	}
+	return fmt.Sprintf("%d invalid payees: %s", len(e.Lines), strings.Join(msgs, "; "))
+}
+
+//B2CBatch pays a list of payees with B2C, results are correlated to their line by
+//OriginatorConversationID, serve Handler() (or pass Deliver to your own
+//B2CResultHandler) on the ResultCallBackURL of the template
+type B2CBatch struct {
+	//Mpesa sends the payments, e.g. *Mpesa or a mock in tests
+	Mpesa B2CAPI
+	//Template provides the fields shared by every payment e.g. initiator, ShortCode,
+	//CommandID and callback urls, its PhoneNumber, Amount & OriginatorConversationID are ignored
+	Template B2C
+	//Concurrency optional defaults to DefaultBatchConcurrency
+	Concurrency int
+	//Rate optional max requests per second, defaults to DefaultBatchRate
+	Rate int
+
+	mu      sync.Mutex
+	lines   map[string]*PayoutLine
+	order   []string
+	changed chan struct{}
+}
+
+//NewB2CBatch returns *B2CBatch
+func NewB2CBatch(s B2CAPI, template *B2C) *B2CBatch {
+	return &B2CBatch{
+		Mpesa:    s,
+		Template: *template,
+		lines:    map[string]*PayoutLine{},
+		changed:  make(chan struct{}),
+	}
+}
+
+//b2c returns the validated b2c request of payee
+func (b *B2CBatch) b2c(payee *Payee) (b2c *B2C, err error) {
+	if payee.ID == "" {
+		err = fmt.Errorf("Must provide payee ID")
+		return
+	}
+	req := b.Template
+	b2c = &req
+	b2c.PhoneNumber = payee.PhoneNumber
+	b2c.Amount = payee.Amount
+	b2c.OriginatorConversationID = payee.ID
+	if payee.Remarks != "" {
+		b2c.Remarks = payee.Remarks
+	}
+	if payee.Occasion != "" {
+		b2c.Occasion = payee.Occasion
+	}
+	err = b2c.OK()
+	return
+}
+
+//Submit validates every payee, then pays them with bounded concurrency and rate.
+//It returns a *BatchValidationError without sending anything if a payee is invalid,
+//otherwise a report once every payment was sent, see Wait for their results.
+func (b *B2CBatch) Submit(ctx context.Context, payees []*Payee) (report *BatchReport, err error) {
+	requests := make([]*B2C, len(payees))
+	validation := &BatchValidationError{}
+	seen := map[string]bool{}
+	b.mu.Lock()
+	for i, payee := range payees {
+		requests[i], err = b.b2c(payee)
+		if err == nil && (seen[payee.ID] || b.lines[payee.ID] != nil) {
+			err = fmt.Errorf("Duplicate payee ID")
+		}
+		if err != nil {
+			validation.Lines = append(validation.Lines, LineError{Line: i, ID: payee.ID, Err: err})
+		}
+		seen[payee.ID] = true
+	}
+	if len(validation.Lines) > 0 {
+		b.mu.Unlock()
+		err = validation
+		return
+	}
+	err = nil
+	for i, payee := range payees {
+		b.lines[payee.ID] = &PayoutLine{Payee: *payee}
+		//the normalized phone number
+		b.lines[payee.ID].Payee.PhoneNumber = requests[i].PhoneNumber
+		b.order = append(b.order, payee.ID)
+	}
+	b.mu.Unlock()
+
+	concurrency := b.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	rate := b.Rate
+	if rate <= 0 {
+		rate = DefaultBatchRate
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	queue := make(chan *B2C)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range queue {
+				b.send(ctx, req, ticker.C)
+			}
+		}()
+	}
+	for _, req := range requests {
+		queue <- req
+	}
+	close(queue)
+	wg.Wait()
+	report = b.Report()
+	return
+}
+
+//send pays req once the rate allows it and records the outcome on its line,
+//a line is only failed when the payment certainly was not made
+func (b *B2CBatch) send(ctx context.Context, req *B2C, tick <-chan time.Time) {
+	id := req.OriginatorConversationID
+	select {
+	case <-tick:
+	case <-ctx.Done():
+		b.update(id, false, func(line *PayoutLine) {
+			line.Status = PayoutFailed
+			line.Err = ctx.Err()
+		})
+		return
+	}
+	res, err := b.Mpesa.B2CCtx(ctx, req)
+	b.update(id, false, func(line *PayoutLine) {
+		line.Err = err
+		switch {
+		case err == nil && res.ResponseCode != "0":
+			line.Status = PayoutFailed
+			line.Err = &APIError{ErrorCode: res.ResponseCode, ErrorMessage: res.ResponseDescription}
+		case err == nil:
+			line.ConversationID = res.ConversationID
+		case duplicateOriginator(err):
+			line.Status = PayoutDuplicate
+		case rejected(err):
+			line.Status = PayoutFailed
+		}
+		//otherwise the request may have reached daraja and its result can still arrive
+	})
+}
+
+//duplicateOriginator reports whether err is daraja rejecting an OriginatorConversationID it already received
+func duplicateOriginator(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && errors.Is(apiErr, ErrDuplicateRequest) &&
+		strings.Contains(strings.ToLower(apiErr.ErrorMessage), "originatorconversationid")
+}
+
+//rejected reports whether err certainly means daraja did not process the payment,
+//i.e. the request was not sent, a 4xx response or a subscriber locked by another transaction
+func rejected(err error) bool {
+	if errors.Is(err, ErrNotSent) {
+		return true
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+		return true
+	}
+	return errors.Is(apiErr, ErrSubscriberLocked)
+}
+
+//update applies fn to the line id and wakes up Wait. A result is final, once it is
+//recorded the line no longer changes, other updates only apply to pending lines.
+func (b *B2CBatch) update(id string, result bool, fn func(line *PayoutLine)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	line, ok := b.lines[id]
+	if !ok || line.ResultCode != "" || (!result && line.Status != PayoutPending) {
+		return
+	}
+	fn(line)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+//Handler returns a B2CResultHandler delivering every result to b
+func (b *B2CBatch) Handler() *B2CResultHandler {
+	return NewB2CResultHandler(b.Deliver, b.Deliver)
+}
+
+//Deliver records res on its line, overriding a failure or duplicate reported when
+//sending the payment, results of other payments are ignored
+func (b *B2CBatch) Deliver(ctx context.Context, res *B2CResult) (err error) {
+	b.update(res.OriginatorConversationID, true, func(line *PayoutLine) {
+		line.Status = PayoutFailed
+		if res.Code().Success() {
+			line.Status = PayoutPaid
+			line.Err = nil
+		}
+		line.ConversationID = res.ConversationID
+		line.TransactionReceipt = res.TransactionReceipt
+		line.ResultCode = res.RawResultCode
+		line.ResultDesc = res.ResultDesc
+	})
+	return
+}
+
+//Report returns a snapshot of every line of the batch
+func (b *B2CBatch) Report() (report *BatchReport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	report = &BatchReport{}
+	for _, id := range b.order {
+		line := *b.lines[id]
+		report.Lines = append(report.Lines, line)
+		summary := &report.Summary
+		summary.Total++
+		switch line.Status {
+		case PayoutPaid:
+			summary.Paid++
+			summary.PaidAmount = summary.PaidAmount.Add(line.Payee.Amount)
+		case PayoutFailed:
+			summary.Failed++
+		case PayoutPending:
+			summary.Pending++
+		case PayoutDuplicate:
+			summary.Duplicate++
+		}
+	}
+	return
+}
+
+//Wait blocks until no line is pending or ctx is done, it returns the report either way
+//with ctx.Err() in the latter case. A line pending with Err set was sent but its outcome
+//is unknown e.g. the response was lost, if its result never arrives check the payment
+//with TransactionStatus before paying it again.
+func (b *B2CBatch) Wait(ctx context.Context) (report *BatchReport, err error) {
+	for {
+		b.mu.Lock()
+		changed := b.changed
+		b.mu.Unlock()
+		report = b.Report()
+		if report.Summary.Pending == 0 {
+			return
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
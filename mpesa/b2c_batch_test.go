@@ -0,0 +1,198 @@
+package mpesa_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+func newTestBatch(s mpesa.B2CAPI) *mpesa.B2CBatch {
+	return mpesa.NewB2CBatch(s, &mpesa.B2C{
+		InitiatorUserName: "testapi",
+		InitiatorPassword: "Safaricom007@",
+		ShortCode:         "600000",
+		ResultCallBackURL: "http://127.0.0.1:1/result",
+	})
+}
+
+func submitPayee(t *testing.T, batch *mpesa.B2CBatch, id string) mpesa.PayoutLine {
+	t.Helper()
+	report, err := batch.Submit(context.Background(), []*mpesa.Payee{
+		{ID: id, PhoneNumber: "0712345678", Amount: mpesa.KES(100)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.Lines[len(report.Lines)-1]
+}
+
+func payoutResult(id, code string) *mpesa.B2CResult {
+	return &mpesa.B2CResult{Result: mpesa.Result{
+		RawResultCode:            code,
+		OriginatorConversationID: id,
+		ConversationID:           "AG_20191219_00004e48cf7e3533f581",
+	}}
+}
+
+func TestB2CBatchKeepsAmbiguousFailuresPending(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	batch := newTestBatch(newTestMpesa(t, srv))
+
+	srv.RespondWithAPIError(mpesatest.B2CV3Path, systemBusy)
+	line := submitPayee(t, batch, "payout-1")
+	if line.Status != mpesa.PayoutPending || !errors.Is(line.Err, mpesa.ErrSystemBusy) {
+		t.Fatalf("expected a pending line with its error, got %v, %v", line.Status, line.Err)
+	}
+	err := batch.Deliver(context.Background(), payoutResult("payout-1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	report, err := batch.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line = report.Lines[0]; line.Status != mpesa.PayoutPaid || line.Err != nil {
+		t.Fatalf("expected the result to mark the line paid, got %v, %v", line.Status, line.Err)
+	}
+}
+
+func TestB2CBatchFailsRejectedPayments(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	batch := newTestBatch(newTestMpesa(t, srv))
+
+	srv.RespondWithAPIError(mpesatest.B2CV3Path, &mpesa.APIError{
+		StatusCode:   http.StatusBadRequest,
+		ErrorCode:    "400.002.02",
+		ErrorMessage: "Bad Request - Invalid Amount",
+	})
+	if line := submitPayee(t, batch, "payout-1"); line.Status != mpesa.PayoutFailed {
+		t.Fatalf("expected a 4xx to fail the line, got %v, %v", line.Status, line.Err)
+	}
+	srv.RespondWithAPIError(mpesatest.B2CV3Path, &mpesa.APIError{
+		StatusCode:   http.StatusInternalServerError,
+		ErrorCode:    "500.001.1001",
+		ErrorMessage: "Unable to lock subscriber, a transaction is already in process for the current subscriber",
+	})
+	line := submitPayee(t, batch, "payout-2")
+	if line.Status != mpesa.PayoutFailed || !errors.Is(line.Err, mpesa.ErrSubscriberLocked) {
+		t.Fatalf("expected a locked subscriber to fail the line, got %v, %v", line.Status, line.Err)
+	}
+}
+
+func TestB2CBatchDuplicateOriginatorConversationID(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	submitPayee(t, newTestBatch(s), "payout-1")
+	batch := newTestBatch(s)
+	line := submitPayee(t, batch, "payout-1")
+	if line.Status != mpesa.PayoutDuplicate {
+		t.Fatalf("expected the payment to be a duplicate, got %v, %v", line.Status, line.Err)
+	}
+	//the result of the first submission still tells the outcome
+	err := batch.Deliver(context.Background(), payoutResult("payout-1", "2001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line = batch.Report().Lines[0]; line.Status != mpesa.PayoutFailed || line.ResultCode != "2001" {
+		t.Fatalf("expected the result to fail the line, got %v, %q", line.Status, line.ResultCode)
+	}
+	//results are final
+	err = batch.Deliver(context.Background(), payoutResult("payout-1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line = batch.Report().Lines[0]; line.Status != mpesa.PayoutFailed {
+		t.Fatalf("expected a second result to be ignored, got %v", line.Status)
+	}
+}
+
+func TestB2CBatchFailsPaymentsNotSent(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Transport = mpesa.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == mpesatest.B2CV3Path {
+			return nil, errors.New("dial tcp: lookup sandbox.safaricom.co.ke: no such host")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	s, err := mpesa.NewMpesa(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := newTestBatch(s)
+
+	line := submitPayee(t, batch, "payout-1")
+	if line.Status != mpesa.PayoutFailed || !errors.Is(line.Err, mpesa.ErrNotSent) {
+		t.Fatalf("expected a payment never sent to fail its line, got %v, %v", line.Status, line.Err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err = batch.Wait(ctx); err != nil {
+		t.Fatalf("expected Wait to return, got %v", err)
+	}
+}
+
+func TestB2CBatchKeepsLostResponsesPending(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Transport = dropFirstResponse(mpesatest.B2CV3Path)
+	s, err := mpesa.NewMpesa(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := newTestBatch(s)
+
+	line := submitPayee(t, batch, "payout-1")
+	if line.Status != mpesa.PayoutPending || line.Err == nil || errors.Is(line.Err, mpesa.ErrNotSent) {
+		t.Fatalf("expected a pending line with its error, got %v, %v", line.Status, line.Err)
+	}
+}
+
+//mockB2C is a B2CAPI accepting every payment without sending it
+type mockB2C struct {
+	mu   sync.Mutex
+	sent []*mpesa.B2C
+}
+
+func (m *mockB2C) B2C(b2c *mpesa.B2C) (*mpesa.APIRes, error) {
+	return m.B2CCtx(context.Background(), b2c)
+}
+
+func (m *mockB2C) B2CCtx(ctx context.Context, b2c *mpesa.B2C) (*mpesa.APIRes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, b2c)
+	return &mpesa.APIRes{ResponseCode: "0", ConversationID: "AG_" + b2c.OriginatorConversationID}, nil
+}
+
+func (m *mockB2C) ParseB2CResult(b2cResultRes io.Reader) (*mpesa.B2CResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestB2CBatchWithMockClient(t *testing.T) {
+	mock := &mockB2C{}
+	batch := newTestBatch(mock)
+
+	line := submitPayee(t, batch, "payout-1")
+	if line.Status != mpesa.PayoutPending || line.ConversationID != "AG_payout-1" {
+		t.Fatalf("expected the mock to accept the payment, got %+v", line)
+	}
+	if len(mock.sent) != 1 || mock.sent[0].Amount != mpesa.KES(100) {
+		t.Fatalf("expected a single payment of KES 100, got %+v", mock.sent)
+	}
+}
@@ -0,0 +1,99 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+//b2cTimeLayout is the layout of TransactionCompletedDateTime e.g. 19.12.2019 11:45:50
+const b2cTimeLayout = "02.01.2006 15:04:05"
+
+//B2CResult is the typed result of a b2c payment, the fields other than Result
+//and Occasion are only set for successful payments. OriginatorConversationID is
+//the one set on the B2C model when the payment was sent to the v3 endpoint.
+type B2CResult struct {
+	Result
+	TransactionAmount  Amount
+	TransactionReceipt string
+	//ReceiverPartyPublicName e.g. "254722000000 - John Doe"
+	ReceiverPartyPublicName string
+	//TransactionCompletedDateTime in the Africa/Nairobi timezone
+	TransactionCompletedDateTime        time.Time
+	B2CUtilityAccountAvailableFunds     Amount
+	B2CWorkingAccountAvailableFunds     Amount
+	B2CChargesPaidAccountAvailableFunds Amount
+	B2CRecipientIsRegisteredCustomer    bool
+	//Occasion of the payment, from ReferenceData
+	Occasion string
+}
+
+//newB2CResult converts the parameters of result
+func newB2CResult(result *Result) (b2cResult *B2CResult, err error) {
+	completed, err := result.paramTime("TransactionCompletedDateTime", b2cTimeLayout)
+	if err != nil {
+		return
+	}
+	res := &B2CResult{
+		Result:                           *result,
+		TransactionReceipt:               result.paramString("TransactionReceipt"),
+		ReceiverPartyPublicName:          result.paramString("ReceiverPartyPublicName"),
+		TransactionCompletedDateTime:     completed,
+		B2CRecipientIsRegisteredCustomer: result.paramString("B2CRecipientIsRegisteredCustomer") == "Y",
+	}
+	err = result.paramAmounts(map[string]*Amount{
+		"TransactionAmount":                   &res.TransactionAmount,
+		"B2CUtilityAccountAvailableFunds":     &res.B2CUtilityAccountAvailableFunds,
+		"B2CWorkingAccountAvailableFunds":     &res.B2CWorkingAccountAvailableFunds,
+		"B2CChargesPaidAccountAvailableFunds": &res.B2CChargesPaidAccountAvailableFunds,
+	})
+	if err != nil {
+		return
+	}
+	//daraja spells it both ways
+	for _, key := range []string{"Occasion", "Occassion"} {
+		if occasion, ok := result.Reference(key); ok && occasion != nil {
+			res.Occasion = fmt.Sprint(occasion)
+		}
+	}
+	b2cResult = res
+	return
+}
+
+//ParseB2CResult parses the payload posted to the ResultCallBackURL of a b2c payment
+func (s *Mpesa) ParseB2CResult(b2cResultRes io.Reader) (b2cResult *B2CResult, err error) {
+	result, err := parseResult(b2cResultRes)
+	if err != nil {
+		return
+	}
+	return newB2CResult(result)
+}
+
+//B2CResultFunc handles a parsed b2c result, returning an error makes
+//the handler respond with a 500 status
+type B2CResultFunc func(ctx context.Context, res *B2CResult) error
+
+//B2CResultHandler is an http.Handler for the b2c ResultCallBackURL,
+//successful payments are passed to OnSuccess and all other outcomes to OnFailure.
+//Use a QueueTimeoutHandler for the TimeOutCallBackURL.
+type B2CResultHandler struct {
+	OnSuccess B2CResultFunc
+	OnFailure B2CResultFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewB2CResultHandler returns *B2CResultHandler, either hook may be nil
+func NewB2CResultHandler(onSuccess, onFailure B2CResultFunc) *B2CResultHandler {
+	return &B2CResultHandler{
+		OnSuccess: onSuccess,
+		OnFailure: onFailure,
+	}
+}
+
+//ServeHTTP parses the result, dispatches it and acknowledges it to daraja
+func (h *B2CResultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, r, h.MaxBodyBytes, newB2CResult, h.OnSuccess, h.OnFailure)
+}
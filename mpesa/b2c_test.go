@@ -0,0 +1,92 @@
+package mpesa_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+//originatorPayment returns a b2c payment sent with originatorConversationID
+func originatorPayment(originatorConversationID string) *mpesa.B2C {
+	b2c := b2cPayment()
+	b2c.Occasion = "salary"
+	b2c.OriginatorConversationID = originatorConversationID
+	return b2c
+}
+
+func TestB2COriginatorConversationIDEndpoint(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	res, err := s.B2C(originatorPayment("payout-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OriginatorConversationID != "payout-1" {
+		t.Fatalf("expected the id to be echoed back, got %+v", res)
+	}
+	var payload mpesa.B2CPayload
+	err = srv.LastRequest(mpesatest.B2CV3Path).Decode(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.OriginatorConversationID != "payout-1" || payload.PartyB != "254712345678" || payload.Amount != "100" || payload.Occassion != "salary" {
+		t.Fatalf("unexpected v3 payload %+v", payload)
+	}
+
+	_, err = s.B2C(originatorPayment(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(mpesatest.B2CV3Path)); n != 1 {
+		t.Fatalf("expected a payment without OriginatorConversationID not to use v3, got %d v3 requests", n)
+	}
+	var fields map[string]interface{}
+	err = srv.LastRequest(mpesatest.B2CPath).Decode(&fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["OriginatorConversationID"]; ok {
+		t.Fatalf("expected the v1 payload without OriginatorConversationID, got %v", fields)
+	}
+	if fields["PartyB"] != "254712345678" || fields["CommandID"] != mpesa.BusinessPayment {
+		t.Fatalf("unexpected v1 payload %v", fields)
+	}
+}
+
+func TestB2COriginatorConversationIDValidation(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	tests := []struct {
+		name string
+		id   string
+		ok   bool
+	}{
+		{"max length", strings.Repeat("a", 100), true},
+		{"inner spaces", "payout 1", true},
+		{"too long", strings.Repeat("a", 101), false},
+		{"leading space", " payout-1", false},
+		{"trailing newline", "payout-1\n", false},
+		{"only spaces", "   ", false},
+	}
+	for _, test := range tests {
+		_, err := s.B2C(originatorPayment(test.id))
+		if test.ok != (err == nil) {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+	}
+	if n := len(srv.Requests(mpesatest.B2CV3Path)); n != 2 {
+		t.Fatalf("expected only the valid payments to be sent, got %d", n)
+	}
+
+	payment := originatorPayment("payout-1")
+	payment.Occasion = strings.Repeat("a", 101)
+	if _, err := s.B2C(payment); err == nil {
+		t.Fatal("expected an error for a long Occasion")
+	}
+}
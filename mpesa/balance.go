@@ -1,13 +1,17 @@
 package mpesa
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"regexp"
 )
 
 //BalanceQueryAPI service interface
 type BalanceQueryAPI interface {
 	BalanceQuery(balanceQuery *BalanceQuery) (apiRes *APIRes, err error)
+	BalanceQueryCtx(ctx context.Context, balanceQuery *BalanceQuery) (apiRes *APIRes, err error)
+	ParseBalanceQueryRes(balanceQueryRes io.Reader) (res *BalanceQueryRes, err error)
 }
 
 //BalanceQuery model
@@ -86,12 +90,13 @@ type BalanceQueryPayload struct {
 	ResultURL string `json:"ResultURL"`
 }
 
-//BalanceQueryRes response
-type BalanceQueryRes struct {
+//BalanceQuery returns the account balance, the balance is sent to the result callback url
+func (s *Mpesa) BalanceQuery(balanceQuery *BalanceQuery) (apiRes *APIRes, err error) {
+	return s.BalanceQueryCtx(context.Background(), balanceQuery)
 }
 
-//BalanceQuery retur
-func (s *Mpesa) BalanceQuery(balanceQuery *BalanceQuery) (apiRes *APIRes, err error) {
+//BalanceQueryCtx is BalanceQuery bound to ctx
+func (s *Mpesa) BalanceQueryCtx(ctx context.Context, balanceQuery *BalanceQuery) (apiRes *APIRes, err error) {
 	err = balanceQuery.OK()
 	if err != nil {
 		return
@@ -112,6 +117,6 @@ func (s *Mpesa) BalanceQuery(balanceQuery *BalanceQuery) (apiRes *APIRes, err er
 		ResultURL:          balanceQuery.ResultCallBackURL,
 	}
 	endpoint := "/mpesa/accountbalance/v1/query"
-	apiRes, err = s.APIRes(endpoint, payload)
+	apiRes, err = s.apiRes(ctx, endpoint, payload, true)
 	return
 }
@@ -0,0 +1,130 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//BalanceAccount is an account of a balance query result
+type BalanceAccount struct {
+	//Name e.g. "Working Account", "Utility Account"
+	Name      string
+	Currency  string
+	Current   Amount
+	Available Amount
+	Reserved  Amount
+	Uncleared Amount
+}
+
+//BalanceQueryRes is the typed result of a balance query, Accounts and
+//BOCompletedTime are only set for successful queries
+type BalanceQueryRes struct {
+	Result
+	Accounts []BalanceAccount
+	//BOCompletedTime in the Africa/Nairobi timezone
+	BOCompletedTime time.Time
+}
+
+//Account returns the account called name e.g. "Utility Account"
+func (r *BalanceQueryRes) Account(name string) (account BalanceAccount, ok bool) {
+	for _, account = range r.Accounts {
+		if strings.EqualFold(account.Name, name) {
+			return account, true
+		}
+	}
+	return BalanceAccount{}, false
+}
+
+//parseAccountBalance parses the accounts of an AccountBalance parameter e.g.
+//"Working Account|KES|481000.00|481000.00|0.00|0.00&Float Account|KES|0.00|0.00|0.00|0.00"
+func parseAccountBalance(accountBalance string) (accounts []BalanceAccount, err error) {
+	accountBalance = strings.TrimSpace(accountBalance)
+	if accountBalance == "" {
+		return
+	}
+	for _, account := range strings.Split(accountBalance, "&") {
+		fields := strings.Split(account, "|")
+		if len(fields) != 6 {
+			err = fmt.Errorf("Invalid account balance %q", account)
+			return
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		amounts := make([]Amount, 4)
+		for i, amount := range fields[2:] {
+			amounts[i], err = ParseAmount(amount)
+			if err != nil {
+				err = fmt.Errorf("Invalid amount %q in account balance %q", amount, account)
+				return
+			}
+		}
+		accounts = append(accounts, BalanceAccount{
+			Name:      fields[0],
+			Currency:  fields[1],
+			Current:   amounts[0],
+			Available: amounts[1],
+			Reserved:  amounts[2],
+			Uncleared: amounts[3],
+		})
+	}
+	return
+}
+
+//newBalanceQueryRes converts the parameters of result
+func newBalanceQueryRes(result *Result) (res *BalanceQueryRes, err error) {
+	completed, err := result.paramTime("BOCompletedTime", darajaTimeLayout)
+	if err != nil {
+		return
+	}
+	accounts, err := parseAccountBalance(result.paramString("AccountBalance"))
+	if err != nil {
+		return
+	}
+	res = &BalanceQueryRes{
+		Result:          *result,
+		Accounts:        accounts,
+		BOCompletedTime: completed,
+	}
+	return
+}
+
+//ParseBalanceQueryRes parses the payload posted to the ResultCallBackURL of a balance query
+func (s *Mpesa) ParseBalanceQueryRes(balanceQueryRes io.Reader) (res *BalanceQueryRes, err error) {
+	result, err := parseResult(balanceQueryRes)
+	if err != nil {
+		return
+	}
+	return newBalanceQueryRes(result)
+}
+
+//BalanceQueryResFunc handles a parsed balance query result, returning an error makes
+//the handler respond with a 500 status
+type BalanceQueryResFunc func(ctx context.Context, res *BalanceQueryRes) error
+
+//BalanceQueryResultHandler is an http.Handler for the balance query ResultCallBackURL,
+//successful queries are passed to OnSuccess and all other outcomes to OnFailure.
+//Use a QueueTimeoutHandler for the TimeOutCallBackURL.
+type BalanceQueryResultHandler struct {
+	OnSuccess BalanceQueryResFunc
+	OnFailure BalanceQueryResFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewBalanceQueryResultHandler returns *BalanceQueryResultHandler, either hook may be nil
+func NewBalanceQueryResultHandler(onSuccess, onFailure BalanceQueryResFunc) *BalanceQueryResultHandler {
+	return &BalanceQueryResultHandler{
+		OnSuccess: onSuccess,
+		OnFailure: onFailure,
+	}
+}
+
+//ServeHTTP parses the result, dispatches it and acknowledges it to daraja
+func (h *BalanceQueryResultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, r, h.MaxBodyBytes, newBalanceQueryRes, h.OnSuccess, h.OnFailure)
+}
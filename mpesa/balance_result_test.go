@@ -0,0 +1,85 @@
+package mpesa_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+//balanceParams returns the result parameters of a balance query with accountBalance as its AccountBalance
+func balanceParams(accountBalance string) string {
+	return `[{"Key":"AccountBalance","Value":"` + accountBalance + `"},{"Key":"BOCompletedTime","Value":20191219102115}]`
+}
+
+func TestParseBalanceQueryRes(t *testing.T) {
+	s := &mpesa.Mpesa{}
+	tests := []struct {
+		name           string
+		accountBalance string
+		accounts       []mpesa.BalanceAccount
+	}{
+		{
+			"single account",
+			"Working Account|KES|481000.00|481000.00|0.00|0.00",
+			[]mpesa.BalanceAccount{
+				{Name: "Working Account", Currency: "KES", Current: mpesa.KES(481000), Available: mpesa.KES(481000)},
+			},
+		},
+		{
+			"several accounts",
+			"Working Account|KES|346568.83|6186.83|340382.00|0.00&Utility Account|KES|10.50|10.50|0.00|0.25",
+			[]mpesa.BalanceAccount{
+				{Name: "Working Account", Currency: "KES", Current: mpesa.Cents(34656883), Available: mpesa.Cents(618683), Reserved: mpesa.KES(340382)},
+				{Name: "Utility Account", Currency: "KES", Current: mpesa.Cents(1050), Available: mpesa.Cents(1050), Uncleared: mpesa.Cents(25)},
+			},
+		},
+		{
+			"spaces around fields",
+			" Float Account | KES | 1.00 | 1.00 | 0.00 | 0.00 ",
+			[]mpesa.BalanceAccount{
+				{Name: "Float Account", Currency: "KES", Current: mpesa.KES(1), Available: mpesa.KES(1)},
+			},
+		},
+		{"empty", "", nil},
+	}
+	for _, test := range tests {
+		res, err := s.ParseBalanceQueryRes(strings.NewReader(resultBody(balanceParams(test.accountBalance), `[]`)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(res.Accounts) != len(test.accounts) {
+			t.Fatalf("%s: expected %d accounts, got %+v", test.name, len(test.accounts), res.Accounts)
+		}
+		for i, account := range test.accounts {
+			if res.Accounts[i] != account {
+				t.Fatalf("%s: expected %+v, got %+v", test.name, account, res.Accounts[i])
+			}
+		}
+		if !res.BOCompletedTime.Equal(time.Date(2019, 12, 19, 7, 21, 15, 0, time.UTC)) {
+			t.Fatalf("%s: expected BOCompletedTime in nairobi, got %v", test.name, res.BOCompletedTime)
+		}
+	}
+
+	res, err := s.ParseBalanceQueryRes(strings.NewReader(resultBody(balanceParams(tests[1].accountBalance), `[]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account, ok := res.Account("utility account"); !ok || account.Uncleared != mpesa.Cents(25) {
+		t.Fatalf("expected the utility account, got %+v", account)
+	}
+
+	for _, accountBalance := range []string{
+		"Working Account|KES|481000.00|481000.00|0.00",
+		"Working Account|KES|481000.00|481000.00|0.00|0.00|0.00",
+		"Working Account|KES|481000.00|481000.00|0.00|0.00&",
+		"Working Account,KES,481000.00,481000.00,0.00,0.00",
+		"Working Account|KES|481,000.00|481000.00|0.00|0.00",
+		"Working Account|KES||481000.00|0.00|0.00",
+	} {
+		if _, err = s.ParseBalanceQueryRes(strings.NewReader(resultBody(balanceParams(accountBalance), `[]`))); err == nil {
+			t.Fatalf("%s: expected an error", accountBalance)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package mpesa
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -8,6 +9,10 @@ import (
 
 //C2BAPI service  interface
 type C2BAPI interface {
+	RegisterURLs(r *RegisterURLs) (c2bRes *C2BRes, err error)
+	RegisterURLsCtx(ctx context.Context, r *RegisterURLs) (c2bRes *C2BRes, err error)
+	C2BSimulate(c2bSimulate *C2BSimulate) (c2bRes *C2BRes, err error)
+	C2BSimulateCtx(ctx context.Context, c2bSimulate *C2BSimulate) (c2bRes *C2BRes, err error)
 }
 
 // C2BRes response
@@ -21,10 +26,10 @@ type C2BRes struct {
 type C2BSimulate struct {
 	ShortCode string `json:"ShortCode"`
 	//optional if not provided will default to CustomerPayBillOnline
-	CommandID     string  `json:"CommandID"`
-	Amount        float32 `json:"Amount"`
-	Msisdn        string  `json:"Msisdn"`
-	BillRefNumber string  `json:"BillRefNumber"`
+	CommandID     string `json:"CommandID"`
+	Amount        Amount `json:"Amount"`
+	Msisdn        string `json:"Msisdn"`
+	BillRefNumber string `json:"BillRefNumber"`
 }
 
 //OK validates
@@ -40,6 +45,10 @@ func (m *C2BSimulate) OK() (err error) {
 		err = fmt.Errorf("Invalid response type")
 		return
 	}
+	if m.Amount.Sign() <= 0 {
+		err = fmt.Errorf("Amount must be > 0")
+		return
+	}
 	if m.Msisdn == "" {
 		err = fmt.Errorf("Must provide Msisdn")
 		return
@@ -66,12 +75,17 @@ func (m *C2BSimulate) OK() (err error) {
 
 //C2BSimulate simulate c2b payment
 func (s *Mpesa) C2BSimulate(c2bSimulate *C2BSimulate) (c2bRes *C2BRes, err error) {
+	return s.C2BSimulateCtx(context.Background(), c2bSimulate)
+}
+
+//C2BSimulateCtx is C2BSimulate bound to ctx
+func (s *Mpesa) C2BSimulateCtx(ctx context.Context, c2bSimulate *C2BSimulate) (c2bRes *C2BRes, err error) {
 	err = c2bSimulate.OK()
 	if err != nil {
 		return
 	}
 	endpoint := "/mpesa/c2b/v1/simulate"
-	c2bRes, err = s.C2BRes(endpoint, c2bSimulate)
+	c2bRes, err = s.C2BResCtx(ctx, endpoint, c2bSimulate)
 	return
 
 }
@@ -116,19 +130,29 @@ func (m *RegisterURLs) OK() (err error) {
 
 //RegisterURLs register validation and confirmation urls
 func (s *Mpesa) RegisterURLs(r *RegisterURLs) (c2bRes *C2BRes, err error) {
+	return s.RegisterURLsCtx(context.Background(), r)
+}
+
+//RegisterURLsCtx is RegisterURLs bound to ctx
+func (s *Mpesa) RegisterURLsCtx(ctx context.Context, r *RegisterURLs) (c2bRes *C2BRes, err error) {
 	err = r.OK()
 	if err != nil {
 		return
 	}
 	endpoint := "/mpesa/c2b/v1/registerurl"
-	c2bRes, err = s.C2BRes(endpoint, r)
+	c2bRes, err = s.C2BResCtx(ctx, endpoint, r)
 	return
 }
 
 //C2BRes send api request
 // this only exists because they mis-spelled "OriginatorCoversationID" hence we cannot use ApiRes
 func (s *Mpesa) C2BRes(endpoint string, payload interface{}) (c2bRes *C2BRes, err error) {
-	rBody, err := s.APIRequest(endpoint, payload)
+	return s.C2BResCtx(context.Background(), endpoint, payload)
+}
+
+//C2BResCtx is C2BRes bound to ctx
+func (s *Mpesa) C2BResCtx(ctx context.Context, endpoint string, payload interface{}) (c2bRes *C2BRes, err error) {
+	rBody, err := s.APIRequestCtx(ctx, endpoint, payload)
 	if err != nil {
 		return
 	}
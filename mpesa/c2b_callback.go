@@ -0,0 +1,184 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//c2b validation rejection codes
+
+//C2BInvalidMSISDN rejection code
+const C2BInvalidMSISDN string = "C2B00011"
+
+//C2BInvalidAccountNumber rejection code
+const C2BInvalidAccountNumber string = "C2B00012"
+
+//C2BInvalidAmount rejection code
+const C2BInvalidAmount string = "C2B00013"
+
+//C2BInvalidKYCDetails rejection code
+const C2BInvalidKYCDetails string = "C2B00014"
+
+//C2BInvalidShortCode rejection code
+const C2BInvalidShortCode string = "C2B00015"
+
+//C2BOtherError rejection code
+const C2BOtherError string = "C2B00016"
+
+//C2BTransaction is the payload daraja posts to the c2b validation and confirmation urls
+type C2BTransaction struct {
+	TransactionType   string `json:"TransactionType"`
+	TransID           string `json:"TransID"`
+	TransTime         string `json:"TransTime"`
+	TransAmount       Amount `json:"TransAmount"`
+	BusinessShortCode string `json:"BusinessShortCode"`
+	BillRefNumber     string `json:"BillRefNumber"`
+	InvoiceNumber     string `json:"InvoiceNumber"`
+	//OrgAccountBalance is zero on validation
+	OrgAccountBalance Amount `json:"OrgAccountBalance"`
+	ThirdPartyTransID string `json:"ThirdPartyTransID"`
+	MSISDN            string `json:"MSISDN"`
+	FirstName         string `json:"FirstName"`
+	MiddleName        string `json:"MiddleName"`
+	LastName          string `json:"LastName"`
+}
+
+//Time parses TransTime
+func (t *C2BTransaction) Time() (time.Time, error) {
+	return parseTime(t.TransTime, darajaTimeLayout)
+}
+
+//validate checks the fields every c2b callback carries
+func (t *C2BTransaction) validate() (err error) {
+	if t.TransID == "" || t.BusinessShortCode == "" {
+		err = fmt.Errorf("Missing TransID or BusinessShortCode")
+		return
+	}
+	if t.TransAmount.Sign() <= 0 {
+		err = fmt.Errorf("Missing TransAmount")
+	}
+	return
+}
+
+//C2BAck is the response the c2b handlers send back to daraja,
+//unlike other callbacks its ResultCode is a string
+type C2BAck struct {
+	ResultCode string `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
+}
+
+//C2BRejection is returned by a C2BCallbackFunc to reject a payment on validation
+type C2BRejection struct {
+	//Code one of the C2B000xx rejection codes e.g. C2BInvalidAccountNumber
+	Code string
+	Desc string
+}
+
+//Error returns error message
+func (e *C2BRejection) Error() string {
+	if e.Desc != "" {
+		return e.Desc
+	}
+	return "Rejected: " + e.Code
+}
+
+//RejectC2B returns a *C2BRejection with code and desc
+func RejectC2B(code, desc string) error {
+	return &C2BRejection{Code: code, Desc: desc}
+}
+
+//C2BCallbackFunc handles a c2b validation or confirmation
+type C2BCallbackFunc func(ctx context.Context, t *C2BTransaction) error
+
+//parseC2BCallback reads and validates the c2b payload of r, on failure it
+//responds to the request and ok is false
+func parseC2BCallback(w http.ResponseWriter, r *http.Request, maxBytes int64) (t *C2BTransaction, ok bool) {
+	body, ok := readCallback(w, r, maxBytes)
+	if !ok {
+		return
+	}
+	t = &C2BTransaction{}
+	err := json.Unmarshal(body, t)
+	if err == nil {
+		err = t.validate()
+	}
+	if err != nil {
+		ok = false
+		writeCallbackAck(w, http.StatusBadRequest, &C2BAck{ResultCode: C2BOtherError, ResultDesc: "Malformed callback: " + err.Error()})
+	}
+	return
+}
+
+//C2BValidationHandler is an http.Handler for the c2b ValidationURL.
+//Payments are accepted when Validate returns nil and rejected with the code & desc of
+//a returned *C2BRejection, any other error rejects them with C2BOtherError.
+type C2BValidationHandler struct {
+	Validate C2BCallbackFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewC2BValidationHandler returns *C2BValidationHandler
+func NewC2BValidationHandler(validate C2BCallbackFunc) *C2BValidationHandler {
+	return &C2BValidationHandler{Validate: validate}
+}
+
+//ServeHTTP parses the payment, validates it and answers daraja with the decision
+func (h *C2BValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, ok := parseC2BCallback(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+	var err error
+	if h.Validate != nil {
+		err = h.Validate(r.Context(), t)
+	}
+	if err == nil {
+		writeCallbackAck(w, http.StatusOK, &C2BAck{ResultCode: "0", ResultDesc: "Accepted"})
+		return
+	}
+	ack := &C2BAck{ResultCode: C2BOtherError, ResultDesc: "Rejected"}
+	var rejection *C2BRejection
+	if errors.As(err, &rejection) {
+		if rejection.Code != "" {
+			ack.ResultCode = rejection.Code
+		}
+		if rejection.Desc != "" {
+			ack.ResultDesc = rejection.Desc
+		}
+	}
+	writeCallbackAck(w, http.StatusOK, ack)
+}
+
+//C2BConfirmationHandler is an http.Handler for the c2b ConfirmationURL,
+//an error from OnConfirm makes the handler respond with a 500 status
+type C2BConfirmationHandler struct {
+	OnConfirm C2BCallbackFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewC2BConfirmationHandler returns *C2BConfirmationHandler
+func NewC2BConfirmationHandler(onConfirm C2BCallbackFunc) *C2BConfirmationHandler {
+	return &C2BConfirmationHandler{OnConfirm: onConfirm}
+}
+
+//ServeHTTP parses the payment, dispatches it and acknowledges it to daraja
+func (h *C2BConfirmationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, ok := parseC2BCallback(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+	if h.OnConfirm != nil {
+		err := h.OnConfirm(r.Context(), t)
+		if err != nil {
+			writeCallbackAck(w, http.StatusInternalServerError, &C2BAck{ResultCode: "1", ResultDesc: "Confirmation not processed"})
+			return
+		}
+	}
+	writeCallbackAck(w, http.StatusOK, &C2BAck{ResultCode: "0", ResultDesc: "Success"})
+}
@@ -0,0 +1,118 @@
+package mpesa_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+const c2bPayment = `{"TransactionType":"Pay Bill","TransID":"NLJ41HAY6Q","TransTime":"20191122063845",
+"TransAmount":"10.00","BusinessShortCode":"600000","BillRefNumber":"account-1","MSISDN":"254708374149"}`
+
+//postC2B posts body to h and returns the status code and decoded ack
+func postC2B(t *testing.T, h http.Handler, body string) (int, *mpesa.C2BAck) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/c2b", strings.NewReader(body)))
+	ack := &mpesa.C2BAck{}
+	if err := json.Unmarshal(w.Body.Bytes(), ack); err != nil {
+		t.Fatalf("undecodable ack %q: %v", w.Body.String(), err)
+	}
+	return w.Code, ack
+}
+
+func TestC2BValidationRejectionCodes(t *testing.T) {
+	codes := []string{
+		mpesa.C2BInvalidMSISDN,
+		mpesa.C2BInvalidAccountNumber,
+		mpesa.C2BInvalidAmount,
+		mpesa.C2BInvalidKYCDetails,
+		mpesa.C2BInvalidShortCode,
+		mpesa.C2BOtherError,
+	}
+	for i, code := range codes {
+		want := "C2B0001" + string(rune('1'+i))
+		if code != want {
+			t.Fatalf("expected rejection code %s, got %s", want, code)
+		}
+		h := mpesa.NewC2BValidationHandler(func(ctx context.Context, t *mpesa.C2BTransaction) error {
+			return mpesa.RejectC2B(code, "Unknown account "+t.BillRefNumber)
+		})
+		status, ack := postC2B(t, h, c2bPayment)
+		if status != http.StatusOK || ack.ResultCode != code || ack.ResultDesc != "Unknown account account-1" {
+			t.Fatalf("%s: unexpected ack %d %+v", code, status, ack)
+		}
+	}
+
+	tests := []struct {
+		err  error
+		code string
+		desc string
+	}{
+		{nil, "0", "Accepted"},
+		{&mpesa.C2BRejection{Code: mpesa.C2BInvalidAmount}, mpesa.C2BInvalidAmount, "Rejected"},
+		{errors.New("database down"), mpesa.C2BOtherError, "Rejected"},
+	}
+	for _, test := range tests {
+		h := mpesa.NewC2BValidationHandler(func(ctx context.Context, t *mpesa.C2BTransaction) error {
+			return test.err
+		})
+		status, ack := postC2B(t, h, c2bPayment)
+		if status != http.StatusOK || ack.ResultCode != test.code || ack.ResultDesc != test.desc {
+			t.Fatalf("%v: unexpected ack %d %+v", test.err, status, ack)
+		}
+	}
+}
+
+func TestC2BHandlersRejectMalformedCallbacks(t *testing.T) {
+	called := false
+	handlers := []*struct {
+		name string
+		h    http.Handler
+	}{
+		{"validation", mpesa.NewC2BValidationHandler(func(ctx context.Context, t *mpesa.C2BTransaction) error {
+			called = true
+			return nil
+		})},
+		{"confirmation", &mpesa.C2BConfirmationHandler{
+			OnConfirm: func(ctx context.Context, t *mpesa.C2BTransaction) error {
+				called = true
+				return nil
+			},
+			MaxBodyBytes: 1024,
+		}},
+	}
+	bodies := []string{
+		`{"TransID":`,
+		`{"TransID":"NLJ41HAY6Q","BusinessShortCode":"600000"}`,
+		`{"TransID":"NLJ41HAY6Q","BusinessShortCode":"600000","TransAmount":"1.2.3"}`,
+	}
+	for _, handler := range handlers {
+		for _, body := range bodies {
+			status, ack := postC2B(t, handler.h, body)
+			if status != http.StatusBadRequest || ack.ResultCode != mpesa.C2BOtherError {
+				t.Fatalf("%s %s: expected a 400, got %d %+v", handler.name, body, status, ack)
+			}
+		}
+	}
+	confirmation := handlers[1].h
+	w := httptest.NewRecorder()
+	large := strings.Replace(c2bPayment, "account-1", strings.Repeat("a", 1024), 1)
+	confirmation.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/c2b", strings.NewReader(large)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected a 413 for a body over MaxBodyBytes, got %d", w.Code)
+	}
+	if called {
+		t.Fatal("expected malformed callbacks not to be dispatched")
+	}
+	status, ack := postC2B(t, confirmation, c2bPayment)
+	if status != http.StatusOK || ack.ResultCode != "0" || !called {
+		t.Fatalf("expected the payment to be confirmed, got %d %+v", status, ack)
+	}
+}
@@ -0,0 +1,56 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+//DefaultMaxCallbackBodyBytes is the largest callback body accepted by the callback handlers
+const DefaultMaxCallbackBodyBytes int64 = 64 << 10
+
+//CallbackAck is the acknowledgement the callback handlers send back to daraja
+type CallbackAck struct {
+	ResultCode int    `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
+}
+
+//AcceptedAck acknowledges a callback that was handled
+var AcceptedAck = &CallbackAck{ResultCode: 0, ResultDesc: "Accepted"}
+
+//writeCallbackAck writes ack as the json response of a callback
+func writeCallbackAck(w http.ResponseWriter, statusCode int, ack interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(ack)
+}
+
+//rejectCallback responds to a callback that could not be handled
+func rejectCallback(w http.ResponseWriter, statusCode int, desc string) {
+	writeCallbackAck(w, statusCode, &CallbackAck{ResultCode: 1, ResultDesc: desc})
+}
+
+//readCallback reads the body of a callback request of at most maxBytes,
+//on failure it responds to the request and ok is false
+func readCallback(w http.ResponseWriter, r *http.Request, maxBytes int64) (body []byte, ok bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		rejectCallback(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxCallbackBodyBytes
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		rejectCallback(w, http.StatusBadRequest, "Unreadable body")
+		return
+	}
+	if int64(len(body)) > maxBytes {
+		rejectCallback(w, http.StatusRequestEntityTooLarge, "Body too large")
+		return
+	}
+	ok = true
+	return
+}
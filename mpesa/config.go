@@ -2,6 +2,8 @@ package mpesa
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 )
 
 //Config basic mpesa configurations
@@ -9,6 +11,24 @@ type Config struct {
 	ConsumerKey    string
 	ConsumerSecret string
 	Environment    string
+	//BaseURL optional overrides the api host picked by Environment,
+	//e.g. to point the client at a local daraja stand in
+	BaseURL string
+	//AuthURL optional overrides the full oauth token url, defaults to
+	//BaseURL + /oauth/v1/generate?grant_type=client_credentials
+	AuthURL string
+	//TokenStore optional defaults to an in memory store per Mpesa service,
+	//set a shared store to reuse one access token across instances
+	TokenStore TokenStore
+	//HTTPClient optional defaults to a client with DefaultTimeout
+	HTTPClient *http.Client
+	//Transport optional replaces the transport of HTTPClient, e.g for proxies or mTLS
+	Transport http.RoundTripper
+	//Middleware optional wraps the transport of both oauth and api requests,
+	//the first middleware is the outermost
+	Middleware []Middleware
+	//RetryPolicy optional, requests are not retried when nil
+	RetryPolicy *RetryPolicy
 }
 
 //OK validates config
@@ -21,9 +41,27 @@ func (c *Config) OK() (err error) {
 		err = fmt.Errorf("ConsumerSecret not set")
 		return
 	}
+	for _, u := range []string{c.BaseURL, c.AuthURL} {
+		if u == "" {
+			continue
+		}
+		parsed, errX := url.Parse(u)
+		if errX != nil || parsed.Scheme == "" || parsed.Host == "" {
+			err = fmt.Errorf("Invalid url %q, must be absolute", u)
+			return
+		}
+	}
 	switch c.Environment {
 	case SandBox, Production:
 		break
+	case "":
+		//with a custom base url the environment only picks the password encryption cert
+		if c.BaseURL != "" {
+			c.Environment = SandBox
+			break
+		}
+		err = fmt.Errorf("Invalid Environment options are: sanbox,production")
+		return
 	default:
 		err = fmt.Errorf("Invalid Environment options are: sanbox,production")
 		return
@@ -0,0 +1,129 @@
+package mpesa
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+//error categories of daraja api errors, compare with errors.Is
+//
+//	if errors.Is(err, mpesa.ErrInsufficientBalance) {...}
+
+//ErrInvalidAccessToken the access token is invalid or expired
+var ErrInvalidAccessToken = errors.New("Invalid access token")
+
+//ErrInvalidCredentials the consumer key or secret was rejected by the oauth endpoint
+var ErrInvalidCredentials = errors.New("Invalid consumer key or secret")
+
+//ErrInvalidRequest the request payload was rejected
+var ErrInvalidRequest = errors.New("Invalid request")
+
+//ErrDuplicateRequest the request was already received e.g. a repeated OriginatorConversationID
+var ErrDuplicateRequest = errors.New("Duplicate request")
+
+//ErrSubscriberLocked another transaction is in process for the same subscriber, try again once it completes
+var ErrSubscriberLocked = errors.New("Subscriber locked")
+
+//ErrInsufficientBalance the account balance is insufficient for the transaction
+var ErrInsufficientBalance = errors.New("Insufficient balance")
+
+//ErrThrottled the request exceeded daraja rate limits or quotas
+var ErrThrottled = errors.New("Too many requests")
+
+//ErrSystemBusy daraja is temporarily unable to process requests
+var ErrSystemBusy = errors.New("System busy")
+
+//ErrTransactionProcessing the transaction has not completed yet, query it again later
+var ErrTransactionProcessing = errors.New("Transaction is being processed")
+
+//ErrNotSent the api request certainly never reached daraja e.g. the request was invalid, the access
+//token could not be fetched or dialing daraja failed, so a request that moves money was not processed
+var ErrNotSent = errors.New("Request not sent")
+
+//errorCodeCategories maps daraja error codes to their category,
+//500.001.1001 is shared by many errors and is classified by its message
+var errorCodeCategories = map[string]error{
+	"400.002.01":   ErrInvalidRequest,
+	"400.002.02":   ErrInvalidRequest,
+	"400.002.05":   ErrInvalidRequest,
+	"400.008.01":   ErrInvalidCredentials,
+	"400.008.02":   ErrInvalidCredentials,
+	"401.003.01":   ErrInvalidAccessToken,
+	"404.001.03":   ErrInvalidAccessToken,
+	"404.001.04":   ErrInvalidAccessToken,
+	"500.003.02":   ErrThrottled,
+	"500.003.03":   ErrThrottled,
+	"500.003.1001": ErrSystemBusy,
+	"503.001.01":   ErrSystemBusy,
+}
+
+//errorMessageCategories classifies errors by their message, checked in order
+var errorMessageCategories = []struct {
+	fragment string
+	category error
+}{
+	{"being processed", ErrTransactionProcessing},
+	{"under processing", ErrTransactionProcessing},
+	{"insufficient", ErrInsufficientBalance},
+	{"unable to lock subscriber", ErrSubscriberLocked},
+	{"already in process", ErrSubscriberLocked},
+	{"duplicate", ErrDuplicateRequest},
+	{"system is busy", ErrSystemBusy},
+	{"spike arrest", ErrThrottled},
+	{"quota violation", ErrThrottled},
+}
+
+//Category returns the error category of e (one of the Err* errors), nil if unknown
+func (e *APIError) Category() error {
+	if category, ok := errorCodeCategories[e.ErrorCode]; ok {
+		return category
+	}
+	message := strings.ToLower(e.ErrorMessage)
+	for _, c := range errorMessageCategories {
+		if strings.Contains(message, c.fragment) {
+			return c.category
+		}
+	}
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrInvalidAccessToken
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusServiceUnavailable:
+		return ErrSystemBusy
+	}
+	return nil
+}
+
+//Is reports whether target is the category of e, it lets errors.Is match the Err* errors
+func (e *APIError) Is(target error) bool {
+	category := e.Category()
+	return category != nil && category == target
+}
+
+//Temporary reports whether the error is expected to clear without changing the request,
+//unclassified errors are temporary when their status code is one of DefaultRetryStatusCodes
+//unless their code is 500.001.1001, which daraja uses for permanent errors e.g. an invalid initiator
+func (e *APIError) Temporary() bool {
+	switch e.Category() {
+	case ErrThrottled, ErrSystemBusy, ErrTransactionProcessing, ErrSubscriberLocked:
+		return true
+	case nil:
+		if e.ErrorCode == "500.001.1001" {
+			return false
+		}
+		for _, statusCode := range DefaultRetryStatusCodes {
+			if e.StatusCode == statusCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Retryable reports whether sending the same request again may succeed,
+//that is the error is temporary or the access token needs to be refreshed
+func (e *APIError) Retryable() bool {
+	return e.Temporary() || e.Category() == ErrInvalidAccessToken
+}
@@ -0,0 +1,41 @@
+package mpesa_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+func TestAPIErrorCategories(t *testing.T) {
+	cases := []struct {
+		err       *mpesa.APIError
+		category  error
+		temporary bool
+	}{
+		{&mpesa.APIError{StatusCode: 500, ErrorCode: "500.001.1001", ErrorMessage: "Unable to lock subscriber, a transaction is already in process for the current subscriber"}, mpesa.ErrSubscriberLocked, true},
+		{&mpesa.APIError{StatusCode: 500, ErrorCode: "500.001.1001", ErrorMessage: "Duplicate OriginatorConversationID"}, mpesa.ErrDuplicateRequest, false},
+		{&mpesa.APIError{StatusCode: 500, ErrorCode: "500.001.1001", ErrorMessage: "The balance is insufficient for the transaction"}, mpesa.ErrInsufficientBalance, false},
+		{&mpesa.APIError{StatusCode: 500, ErrorCode: "500.001.1001", ErrorMessage: "The transaction is being processed"}, mpesa.ErrTransactionProcessing, true},
+		{&mpesa.APIError{StatusCode: 503, ErrorCode: "503.001.01"}, mpesa.ErrSystemBusy, true},
+		{&mpesa.APIError{StatusCode: 400, ErrorCode: "400.002.02", ErrorMessage: "Bad Request - Invalid Amount"}, mpesa.ErrInvalidRequest, false},
+		{&mpesa.APIError{StatusCode: 500, ErrorCode: "500.001.1001", ErrorMessage: "The initiator information is invalid"}, nil, false},
+		{&mpesa.APIError{StatusCode: http.StatusBadGateway}, nil, true},
+	}
+	for _, c := range cases {
+		if c.err.Category() != c.category {
+			t.Errorf("%q: expected category %v, got %v", c.err.Error(), c.category, c.err.Category())
+		}
+		if c.category != nil && !errors.Is(c.err, c.category) {
+			t.Errorf("%q: errors.Is does not match %v", c.err.Error(), c.category)
+		}
+		if c.err.Temporary() != c.temporary {
+			t.Errorf("%q: expected Temporary() %v", c.err.Error(), c.temporary)
+		}
+	}
+	locked := cases[0].err
+	if errors.Is(locked, mpesa.ErrDuplicateRequest) {
+		t.Error("a locked subscriber is not a duplicate request")
+	}
+}
@@ -2,32 +2,39 @@ package mpesa
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"regexp"
-	"strconv"
 	"time"
 )
 
 //ExpressAPI service interface
 type ExpressAPI interface {
 	STKPush(express *Express) (stkPushRes *STKPushRes, err error)
+	STKPushCtx(ctx context.Context, express *Express) (stkPushRes *STKPushRes, err error)
 	ParseSTKCallBackRes(stkCallBackRes io.Reader) (parsedStkRes *ParsedSTKCallBackRes, err error)
 	ExpressTransactionStatus(shortCode, password, checkOutRequestID string) (ts *ExpressTransactionStatusRes, err error)
+	ExpressTransactionStatusCtx(ctx context.Context, shortCode, password, checkOutRequestID string) (ts *ExpressTransactionStatusRes, err error)
+	STKPushAndWait(ctx context.Context, express *Express, opts *STKWaitOptions) (res *ParsedSTKCallBackRes, err error)
 }
 
 //Express model
 type Express struct {
+	//ShortCode is the business short code (head office/store number for buy goods)
+	//used to generate the password
 	ShortCode       string
 	Password        string
 	TransactionType string
-	PhoneNumber     string
-	CallBackURL     string
-	Amount          int
+	//PartyB is the paybill or till number receiving the payment,
+	//optional for CustomerPayBillOnline defaults to ShortCode, required for CustomerBuyGoodsOnline
+	PartyB      string
+	PhoneNumber string
+	CallBackURL string
+	Amount      Amount
 	//AccountRef optional defaults to account
 	AccountRef string
 	//TransactionDesc optional defaults to ""
@@ -55,10 +62,24 @@ func (m *Express) OK() (err error) {
 		break
 	case CustomerPayBillOnline:
 		break
+	case CustomerBuyGoodsOnline:
+		if m.PartyB == "" {
+			err = fmt.Errorf("Must provide PartyB till number for buy goods")
+			return
+		}
+		break
 	default:
 		err = fmt.Errorf("Invalid transaction type")
 		return
 	}
+	//partyB
+	if m.PartyB == "" {
+		m.PartyB = m.ShortCode
+	}
+	if !digitCheck.MatchString(m.PartyB) {
+		err = fmt.Errorf("PartyB must be a valid numeric string")
+		return
+	}
 	phoneNumber, err := FormatPhoneNumber(m.PhoneNumber, "E164")
 	if err != nil {
 		return
@@ -71,10 +92,14 @@ func (m *Express) OK() (err error) {
 		return
 	}
 	//amount
-	if m.Amount < 1 {
+	if m.Amount.Sign() <= 0 {
 		err = fmt.Errorf("Amount must be > 0")
 		return
 	}
+	if !m.Amount.IsWhole() {
+		err = fmt.Errorf("Amount must be a whole number of shillings")
+		return
+	}
 	if m.AccountRef == "" {
 		m.AccountRef = "account"
 	}
@@ -111,8 +136,14 @@ type STKPushRes struct {
 
 //STKPush for express api / Lipa Na Mpesa
 func (s *Mpesa) STKPush(express *Express) (stkPushRes *STKPushRes, err error) {
+	return s.STKPushCtx(context.Background(), express)
+}
+
+//STKPushCtx is STKPush bound to ctx
+func (s *Mpesa) STKPushCtx(ctx context.Context, express *Express) (stkPushRes *STKPushRes, err error) {
 	err = express.OK()
 	if err != nil {
+		err = notSent(err)
 		return
 	}
 	//timestamp
@@ -128,45 +159,19 @@ func (s *Mpesa) STKPush(express *Express) (stkPushRes *STKPushRes, err error) {
 		Password:          password,
 		Timestamp:         timestamp,
 		TransactionType:   express.TransactionType,
-		Amount:            strconv.Itoa(express.Amount),
+		Amount:            express.Amount.String(),
 		PartyA:            express.PhoneNumber,
-		PartyB:            express.ShortCode,
+		PartyB:            express.PartyB,
 		PhoneNumber:       express.PhoneNumber,
 		CallBackURL:       express.CallBackURL,
 		AccountReference:  express.AccountRef,
 		TransactionDesc:   express.TransactionDesc,
 	}
-	jsonPayload, err := json.Marshal(expressPayload)
-	if err != nil {
-		return
-	}
 	apiEndpoint := "/mpesa/stkpush/v1/processrequest"
-	url, err := s.GetBaseURL()
+	resBody, err := s.APIRequestCtx(ctx, apiEndpoint, expressPayload)
 	if err != nil {
 		return
 	}
-	url = url + apiEndpoint
-	bytesReader := bytes.NewReader(jsonPayload)
-	req, err := http.NewRequest(http.MethodPost, url, bytesReader)
-	if err != nil {
-		return
-	}
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := s.MakeRequest(req)
-
-	if err != nil {
-		return
-	}
-	resBody, err := ioutil.ReadAll(res.Body)
-	defer res.Body.Close()
-	if err != nil {
-		return
-	}
-	if res.StatusCode != 200 {
-		err = s.GetAPIError(res.Status, res.StatusCode, resBody)
-		return
-	}
 	stkPushRes = &STKPushRes{}
 	err = json.Unmarshal(resBody, stkPushRes)
 	return
@@ -202,15 +207,34 @@ type ParsedSTKCallBackRes struct {
 	CheckoutRequestID string
 	ResultCode        int
 	ResultDesc        string
-	Meta              struct {
-		Amount             int
-		MpesaReceiptNumber string
-		PhoneNumber        string
-	}
+	Meta              STKCallBackMeta
+}
+
+// STKCallBackMeta is the parsed CallbackMetadata, only sent for successful payments
+type STKCallBackMeta struct {
+	Amount             Amount
+	MpesaReceiptNumber string
+	// Balance zero unless sent by daraja
+	Balance Amount
+	// TransactionDate in the Africa/Nairobi timezone
+	TransactionDate time.Time
+	// PhoneNumber exactly as sent by daraja
+	PhoneNumber string
+	// Extra holds metadata items not listed above by name
+	Extra map[string]interface{}
+}
+
+// Code returns the typed result code of the callback
+func (p *ParsedSTKCallBackRes) Code() ResultCode {
+	return ResultCode(p.ResultCode)
 }
 
 // ParseSTKCallBackRes parses the response from the stk push callback payload
 func (s *Mpesa) ParseSTKCallBackRes(stkCallBackRes io.Reader) (parsedStkRes *ParsedSTKCallBackRes, err error) {
+	return parseSTKCallBack(stkCallBackRes)
+}
+
+func parseSTKCallBack(stkCallBackRes io.Reader) (parsedStkRes *ParsedSTKCallBackRes, err error) {
 	data, err := ioutil.ReadAll(stkCallBackRes)
 	if err != nil {
 		return
@@ -218,7 +242,10 @@ func (s *Mpesa) ParseSTKCallBackRes(stkCallBackRes io.Reader) (parsedStkRes *Par
 
 	stkCallBack := STKCallBackResponse{}
 
-	err = json.Unmarshal(data, &stkCallBack)
+	//decode numbers as json.Number so amounts and phone numbers are kept exactly as sent
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&stkCallBack)
 	if err != nil {
 		return
 	}
@@ -230,23 +257,45 @@ func (s *Mpesa) ParseSTKCallBackRes(stkCallBackRes io.Reader) (parsedStkRes *Par
 	}
 
 	if stkCallBack.Body.StkCallback.CallbackMetadata != nil {
+		meta := &parsedStkRes.Meta
 		for _, item := range stkCallBack.Body.StkCallback.CallbackMetadata.Item {
 
 			switch item.Name {
 			case "Amount":
-				amount, _ := item.Value.(float64)
-				parsedStkRes.Meta.Amount = int(amount)
+				meta.Amount, err = amountValue(item.Value)
+				if err != nil {
+					return
+				}
 				break
 			case "MpesaReceiptNumber":
 				receipt, _ := item.Value.(string)
-				parsedStkRes.Meta.MpesaReceiptNumber = receipt
+				meta.MpesaReceiptNumber = receipt
+				break
+			case "Balance":
+				meta.Balance, err = amountValue(item.Value)
+				if err != nil {
+					return
+				}
+				break
+			case "TransactionDate":
+				if item.Value == nil {
+					break
+				}
+				meta.TransactionDate, err = parseTime(fmt.Sprint(item.Value), darajaTimeLayout)
+				if err != nil {
+					return
+				}
 				break
 			case "PhoneNumber":
-				phoneI, _ := item.Value.(float64)
-				phone := strconv.Itoa(int(phoneI))
-				parsedStkRes.Meta.PhoneNumber = phone
+				if item.Value != nil {
+					meta.PhoneNumber = fmt.Sprint(item.Value)
+				}
 				break
 			default:
+				if meta.Extra == nil {
+					meta.Extra = map[string]interface{}{}
+				}
+				meta.Extra[item.Name] = item.Value
 				break
 			}
 		}
@@ -273,8 +322,18 @@ type ExpressTransactionStatusRes struct {
 	CustomerMessage     string
 }
 
+// Code returns the typed result code of the transaction, ResultCodeUnknown if it has no result yet
+func (r *ExpressTransactionStatusRes) Code() ResultCode {
+	return ParseResultCode(r.ResultCode)
+}
+
 // ExpressTransactionStatus checks the status of Express Payment
 func (s *Mpesa) ExpressTransactionStatus(shortCode, password, checkOutRequestID string) (ts *ExpressTransactionStatusRes, err error) {
+	return s.ExpressTransactionStatusCtx(context.Background(), shortCode, password, checkOutRequestID)
+}
+
+// ExpressTransactionStatusCtx is ExpressTransactionStatus bound to ctx
+func (s *Mpesa) ExpressTransactionStatusCtx(ctx context.Context, shortCode, password, checkOutRequestID string) (ts *ExpressTransactionStatusRes, err error) {
 	// timestamp
 	t := time.Now()
 	layout := "20060102150405"
@@ -289,37 +348,11 @@ func (s *Mpesa) ExpressTransactionStatus(shortCode, password, checkOutRequestID
 		BusinessShortCode: shortCode,
 	}
 
-	data, err := json.Marshal(transactionStatusReq)
-	if err != nil {
-		return
-	}
-
 	apiEndpoint := "/mpesa/stkpushquery/v1/query"
-	url, err := s.GetBaseURL()
-	if err != nil {
-		return
-	}
-	url = url + apiEndpoint
-	bytesReader := bytes.NewReader(data)
-	req, err := http.NewRequest(http.MethodPost, url, bytesReader)
+	rBody, err := s.apiRequest(ctx, apiEndpoint, transactionStatusReq, true)
 	if err != nil {
 		return
 	}
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := s.MakeRequest(req)
-	if err != nil {
-		return
-	}
-	rBody, err := ioutil.ReadAll(res.Body)
-	defer res.Body.Close()
-	if err != nil {
-		return
-	}
-	if res.StatusCode != 200 {
-		err = s.GetAPIError(res.Status, res.StatusCode, rBody)
-		return
-	}
 	ts = &ExpressTransactionStatusRes{}
 	err = json.Unmarshal(rBody, ts)
 	return
@@ -0,0 +1,157 @@
+package mpesa_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+func expressPayment(transactionType, partyB string) *mpesa.Express {
+	return &mpesa.Express{
+		ShortCode:       "174379",
+		Password:        "bfb279f9aa9bdbcf158e97dd71a467cd2e0c893059b10f78e6b72ada1ed2c919",
+		TransactionType: transactionType,
+		PartyB:          partyB,
+		PhoneNumber:     "0712345678",
+		CallBackURL:     "http://127.0.0.1:1/callback",
+		Amount:          mpesa.KES(10),
+	}
+}
+
+func TestExpressPartyB(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	tests := []struct {
+		name            string
+		express         *mpesa.Express
+		transactionType string
+		partyB          string
+	}{
+		{"paybill defaults to ShortCode", expressPayment("", ""), mpesa.CustomerPayBillOnline, "174379"},
+		{"paybill to another paybill", expressPayment(mpesa.CustomerPayBillOnline, "600000"), mpesa.CustomerPayBillOnline, "600000"},
+		{"buy goods till", expressPayment(mpesa.CustomerBuyGoodsOnline, "5566778"), mpesa.CustomerBuyGoodsOnline, "5566778"},
+	}
+	for _, test := range tests {
+		_, err := s.STKPush(test.express)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var payload mpesa.ExpressPayload
+		err = srv.LastRequest(mpesatest.STKPushPath).Decode(&payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload.TransactionType != test.transactionType || payload.PartyB != test.partyB {
+			t.Fatalf("%s: expected %s to %s, got %s to %s", test.name, test.transactionType, test.partyB, payload.TransactionType, payload.PartyB)
+		}
+		if payload.BusinessShortCode != "174379" {
+			t.Fatalf("%s: expected BusinessShortCode 174379, got %s", test.name, payload.BusinessShortCode)
+		}
+	}
+}
+
+func TestExpressValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		express *mpesa.Express
+	}{
+		{"buy goods without PartyB", expressPayment(mpesa.CustomerBuyGoodsOnline, "")},
+		{"non numeric PartyB", expressPayment(mpesa.CustomerPayBillOnline, "till-1")},
+		{"unknown transaction type", expressPayment("CustomerPayOnline", "")},
+	}
+	for _, test := range tests {
+		if err := test.express.OK(); err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+	}
+}
+
+//stkCallback returns a successful stk callback body with items as its CallbackMetadata
+func stkCallback(items string) string {
+	return `{"Body":{"stkCallback":{"MerchantRequestID":"29115-34620561-1","CheckoutRequestID":"ws_CO_191220191020363925",
+	"ResultCode":0,"ResultDesc":"The service request is processed successfully.","CallbackMetadata":{"Item":[` + items + `]}}}}`
+}
+
+func TestParseSTKCallBackRes(t *testing.T) {
+	s := &mpesa.Mpesa{}
+	tests := []struct {
+		name  string
+		items string
+		check func(meta *mpesa.STKCallBackMeta) bool
+	}{
+		{
+			"exact decimal amount",
+			`{"Name":"Amount","Value":346237.55}`,
+			func(meta *mpesa.STKCallBackMeta) bool { return meta.Amount == mpesa.Cents(34623755) },
+		},
+		{
+			"large amount",
+			`{"Name":"Amount","Value":1000000}`,
+			func(meta *mpesa.STKCallBackMeta) bool { return meta.Amount == mpesa.KES(1000000) },
+		},
+		{
+			"missing balance",
+			`{"Name":"Amount","Value":1}`,
+			func(meta *mpesa.STKCallBackMeta) bool { return meta.Balance.IsZero() },
+		},
+		{
+			"null balance",
+			`{"Name":"Balance"}`,
+			func(meta *mpesa.STKCallBackMeta) bool { return meta.Balance.IsZero() },
+		},
+		{
+			"string balance",
+			`{"Name":"Balance","Value":"1500.25"}`,
+			func(meta *mpesa.STKCallBackMeta) bool { return meta.Balance == mpesa.Cents(150025) },
+		},
+		{
+			"phone number beyond float64 precision",
+			`{"Name":"PhoneNumber","Value":25471234567890123}`,
+			func(meta *mpesa.STKCallBackMeta) bool { return meta.PhoneNumber == "25471234567890123" },
+		},
+		{
+			"transaction date in nairobi",
+			`{"Name":"TransactionDate","Value":20191219102115}`,
+			func(meta *mpesa.STKCallBackMeta) bool {
+				return meta.TransactionDate.Equal(time.Date(2019, 12, 19, 7, 21, 15, 0, time.UTC))
+			},
+		},
+		{
+			"receipt and unknown items",
+			`{"Name":"MpesaReceiptNumber","Value":"NLJ7RT61SV"},{"Name":"Reference","Value":"invoice-1"}`,
+			func(meta *mpesa.STKCallBackMeta) bool {
+				return meta.MpesaReceiptNumber == "NLJ7RT61SV" && meta.Extra["Reference"] == "invoice-1"
+			},
+		},
+	}
+	for _, test := range tests {
+		res, err := s.ParseSTKCallBackRes(strings.NewReader(stkCallback(test.items)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if res.CheckoutRequestID != "ws_CO_191220191020363925" || !res.Code().Success() {
+			t.Fatalf("%s: unexpected callback %+v", test.name, res)
+		}
+		if !test.check(&res.Meta) {
+			t.Fatalf("%s: unexpected metadata %+v", test.name, res.Meta)
+		}
+	}
+
+	for _, items := range []string{
+		`{"Name":"Amount","Value":"12.3.4"}`,
+		`{"Name":"Amount","Value":1.005}`,
+		`{"Name":"TransactionDate","Value":"19-12-2019"}`,
+	} {
+		if _, err := s.ParseSTKCallBackRes(strings.NewReader(stkCallback(items))); err == nil {
+			t.Fatalf("%s: expected an error", items)
+		}
+	}
+	if _, err := s.ParseSTKCallBackRes(strings.NewReader(`{"Body":`)); err == nil {
+		t.Fatal("expected an error for a malformed body")
+	}
+}
@@ -1,12 +1,18 @@
 package mpesa
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 //identifier types
@@ -47,6 +53,21 @@ const BusinessPayment string = "BusinessPayment"
 //PromotionPayment b2c commandID
 const PromotionPayment string = "PromotionPayment"
 
+//BusinessPayBill b2b commandID
+const BusinessPayBill string = "BusinessPayBill"
+
+//BusinessBuyGoods b2b commandID
+const BusinessBuyGoods string = "BusinessBuyGoods"
+
+//DisburseFundsToBusiness b2b commandID
+const DisburseFundsToBusiness string = "DisburseFundsToBusiness"
+
+//BusinessToBusinessTransfer b2b commandID
+const BusinessToBusinessTransfer string = "BusinessToBusinessTransfer"
+
+//MerchantToMerchantTransfer b2b commandID
+const MerchantToMerchantTransfer string = "MerchantToMerchantTransfer"
+
 //AccountBalance commandID
 const AccountBalance string = "AccountBalance"
 
@@ -56,13 +77,41 @@ const TransactionStatusQuery string = "TransactionStatusQuery"
 //TransactionReversal commandID
 const TransactionReversal string = "TransactionReversal"
 
+//tokenExpiryMargin is how long before its expiry a cached access token is refreshed
+const tokenExpiryMargin = 60 * time.Second
+
 //Mpesa service implements express, b2c, cb2, b2b, reverse, balance query & transaction query
 type Mpesa struct {
 	Config *Config
+
+	//defaultTokenStore is used when Config.TokenStore is not set
+	tokenStoreOnce    sync.Once
+	defaultTokenStore TokenStore
+
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+//Client is the complete daraja api implemented by *Mpesa,
+//depend on it (or on the per product interfaces) to mock the client in tests
+type Client interface {
+	ExpressAPI
+	C2BAPI
+	B2CAPI
+	B2BAPI
+	ReversalAPI
+	BalanceQueryAPI
+	TransactionStatusAPI
 }
 
-//GetBaseURL returns base api url base on environment
+var _ Client = (*Mpesa)(nil)
+
+//GetBaseURL returns base api url base on environment, Config.BaseURL takes precedence
 func (s *Mpesa) GetBaseURL() (url string, err error) {
+	if s.Config.BaseURL != "" {
+		url = strings.TrimRight(s.Config.BaseURL, "/")
+		return
+	}
 	env := s.Config.Environment
 	switch env {
 	case SandBox:
@@ -82,52 +131,181 @@ type AuthToken struct {
 	ExpiresIn   string `json:"expires_in"`
 }
 
-//GetAuthToken returns *AuthToken
+//GetAuthToken fetches a new *AuthToken from the daraja oauth endpoint
 func (s *Mpesa) GetAuthToken() (authToken *AuthToken, err error) {
+	return s.GetAuthTokenCtx(context.Background())
+}
+
+//authURL returns the oauth token url, Config.AuthURL takes precedence
+func (s *Mpesa) authURL() (url string, err error) {
+	if s.Config.AuthURL != "" {
+		url = s.Config.AuthURL
+		return
+	}
+	url, err = s.GetBaseURL()
+	if err != nil {
+		return
+	}
+	url += "/oauth/v1/generate?grant_type=client_credentials"
+	return
+}
+
+//GetAuthTokenCtx fetches a new *AuthToken from the daraja oauth endpoint, the request is bound to ctx
+func (s *Mpesa) GetAuthTokenCtx(ctx context.Context) (authToken *AuthToken, err error) {
 	consumerKey := s.Config.ConsumerKey
 	consumerSecret := s.Config.ConsumerSecret
 	password := consumerKey + ":" + consumerSecret
 	b64Password := base64.StdEncoding.EncodeToString([]byte(password))
-	baseURL, err := s.GetBaseURL()
+	url, err := s.authURL()
 	if err != nil {
 		return
 	}
-	endpoint := "/oauth/v1/generate?grant_type=client_credentials"
-	url := baseURL + endpoint
-	client := http.Client{}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return
 	}
 	req.Header.Add("Authorization", "Basic "+b64Password)
 	req.Header.Add("Cache-Control", "no-cache")
 
-	res, err := client.Do(req)
+	res, err := s.httpClient().Do(req)
 	if err != nil {
 		return
 	}
 	jsonBody, err := ioutil.ReadAll(res.Body)
+	defer res.Body.Close()
 	if err != nil {
 		return
 	}
+	if res.StatusCode != 200 {
+		err = s.GetAPIError(res.Status, res.StatusCode, jsonBody)
+		return
+	}
 	authToken = &AuthToken{}
 	err = json.Unmarshal(jsonBody, authToken)
+	if err != nil {
+		return
+	}
+	if authToken.AccessToken == "" {
+		err = fmt.Errorf("Empty access token in oauth response")
+	}
+	return
+}
+
+//TTL returns how long the token is valid for as reported by ExpiresIn
+func (t *AuthToken) TTL() (ttl time.Duration, err error) {
+	seconds, err := strconv.Atoi(t.ExpiresIn)
+	if err != nil {
+		err = fmt.Errorf("Invalid expires_in %q in oauth response", t.ExpiresIn)
+		return
+	}
+	ttl = time.Duration(seconds) * time.Second
+	return
+}
+
+//tokenStore returns the configured TokenStore or an in memory store owned by s
+func (s *Mpesa) tokenStore() TokenStore {
+	if s.Config.TokenStore != nil {
+		return s.Config.TokenStore
+	}
+	s.tokenStoreOnce.Do(func() {
+		s.defaultTokenStore = NewMemoryTokenStore()
+	})
+	return s.defaultTokenStore
+}
+
+//tokenKey is the TokenStore key for the configured app, environment and oauth url,
+//so clients of different daraja hosts never share tokens. The consumer key and
+//url are hashed so they are not exposed in shared stores.
+func (s *Mpesa) tokenKey() string {
+	//an invalid environment fails the oauth call itself
+	url, _ := s.authURL()
+	sum := sha256.Sum256([]byte(s.Config.ConsumerKey + "\n" + url))
+	return "mpesa-token-" + s.Config.Environment + "-" + hex.EncodeToString(sum[:8])
+}
+
+//AccessToken returns a cached access token, refreshing it shortly before it expires.
+//Refreshes are serialized with TokenStore.Lock so concurrent callers, even across
+//instances sharing a store, wait on a single oauth call.
+func (s *Mpesa) AccessToken() (token string, err error) {
+	return s.AccessTokenCtx(context.Background())
+}
+
+//AccessTokenCtx is AccessToken bound to ctx, waiting for a refresh by another caller
+//is abandoned when ctx is done
+func (s *Mpesa) AccessTokenCtx(ctx context.Context) (token string, err error) {
+	store := s.tokenStore()
+	key := s.tokenKey()
+	token, ok, err := store.Get(ctx, key)
+	if err != nil || ok {
+		return
+	}
+	unlock, err := store.Lock(ctx, key)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	//another caller may have refreshed the token while we waited for the lock
+	token, ok, err = store.Get(ctx, key)
+	if err != nil || ok {
+		return
+	}
+	authToken, err := s.GetAuthTokenCtx(ctx)
+	if err != nil {
+		return
+	}
+	ttl, err := authToken.TTL()
+	if err != nil {
+		return
+	}
+	// refresh early so a token never expires while a request is in flight,
+	// short lived tokens are still reused for half their lifetime
+	margin := tokenExpiryMargin
+	if ttl < 2*margin {
+		margin = ttl / 2
+	}
+	err = store.Set(ctx, key, authToken.AccessToken, ttl-margin)
+	if err != nil {
+		return
+	}
+	token = authToken.AccessToken
 	return
 }
 
-//MakeRequest makes an authenticated http request to daraja api
+//InvalidateAccessToken drops the cached access token so the next request fetches a new one
+func (s *Mpesa) InvalidateAccessToken() (err error) {
+	return s.InvalidateAccessTokenCtx(context.Background())
+}
+
+//InvalidateAccessTokenCtx is InvalidateAccessToken bound to ctx
+func (s *Mpesa) InvalidateAccessTokenCtx(ctx context.Context) (err error) {
+	return s.tokenStore().Delete(ctx, s.tokenKey())
+}
+
+//MakeRequest makes an authenticated http request to daraja api,
+//the access token is fetched within the request's context
 func (s *Mpesa) MakeRequest(req *http.Request) (res *http.Response, err error) {
-	client := http.Client{}
-	authToken, err := s.GetAuthToken()
+	token, err := s.AccessTokenCtx(req.Context())
 	if err != nil {
 		return
 	}
-	req.Header.Add("Authorization", "Bearer "+authToken.AccessToken)
+	return s.do(req, token)
+}
 
-	return client.Do(req)
+//do sends req authenticated with token
+func (s *Mpesa) do(req *http.Request, token string) (res *http.Response, err error) {
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err = s.httpClient().Do(req)
+	if err != nil {
+		return
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		//token revoked or expired early, make sure the next call fetches a fresh one
+		_ = s.InvalidateAccessTokenCtx(req.Context())
+	}
+	return
 }
 
-//APIError error
+//APIError error, use errors.Is with the Err* errors to check its category
 type APIError struct {
 	RequestID    string `json:"requestId"`
 	ErrorCode    string `json:"errorCode"`
@@ -166,45 +344,34 @@ type APIRes struct {
 
 //APIRequest sends api post request
 func (s *Mpesa) APIRequest(endpoint string, payload interface{}) (resp []byte, err error) {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return
-	}
-	url, err := s.GetBaseURL()
-	if err != nil {
-		return
-	}
-	url += endpoint
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonPayload))
-	if err != nil {
-		return
-	}
-	req.Header.Add("Content-Type", "application/json")
-	res, err := s.MakeRequest(req)
-	if err != nil {
-		return
-	}
-	resp, err = ioutil.ReadAll(res.Body)
-	defer res.Body.Close()
-	if err != nil {
-		return
-	}
-	if res.StatusCode != 200 {
-		err = s.GetAPIError(res.Status, res.StatusCode, resp)
-	}
-	return
+	return s.APIRequestCtx(context.Background(), endpoint, payload)
+}
+
+//APIRequestCtx sends api post request bound to ctx,
+//the request is treated as moving money when retrying (see RetryPolicy)
+func (s *Mpesa) APIRequestCtx(ctx context.Context, endpoint string, payload interface{}) (resp []byte, err error) {
+	return s.apiRequest(ctx, endpoint, payload, false)
 }
 
 //APIRes send api request
 func (s *Mpesa) APIRes(endpoint string, payload interface{}) (apiRes *APIRes, err error) {
-	rBody, err := s.APIRequest(endpoint, payload)
+	return s.APIResCtx(context.Background(), endpoint, payload)
+}
+
+//APIResCtx send api request bound to ctx
+func (s *Mpesa) APIResCtx(ctx context.Context, endpoint string, payload interface{}) (apiRes *APIRes, err error) {
+	return s.apiRes(ctx, endpoint, payload, false)
+}
+
+//apiRes sends api request, idempotent requests are retried freely
+func (s *Mpesa) apiRes(ctx context.Context, endpoint string, payload interface{}, idempotent bool) (apiRes *APIRes, err error) {
+	rBody, err := s.apiRequest(ctx, endpoint, payload, idempotent)
 	if err != nil {
 		return
 	}
 	apiRes = &APIRes{}
 	err = json.Unmarshal(rBody, apiRes)
 	return
-
 }
 
 //NewMpesa returns *Mpesa service
@@ -0,0 +1,105 @@
+package mpesa_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+func newTestMpesa(t *testing.T, srv *mpesatest.Server) *mpesa.Mpesa {
+	t.Helper()
+	s, err := mpesa.NewMpesa(srv.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func balanceQuery() *mpesa.BalanceQuery {
+	return &mpesa.BalanceQuery{
+		InitiatorUserName: "testapi",
+		InitiatorPassword: "Safaricom007@",
+		ShortCode:         "600000",
+		ResultCallBackURL: "http://127.0.0.1:1/result",
+	}
+}
+
+func TestAccessTokenSharesOneOAuthCall(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 50)
+	errs := make([]error, 50)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = s.AccessTokenCtx(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	for i := range tokens {
+		if errs[i] != nil || tokens[i] != mpesatest.DefaultAccessToken {
+			t.Fatalf("caller %d got %q, %v", i, tokens[i], errs[i])
+		}
+	}
+	if n := len(srv.Requests(mpesatest.OAuthPath)); n != 1 {
+		t.Fatalf("expected 1 oauth call, got %d", n)
+	}
+}
+
+func TestUnauthorizedInvalidatesAccessToken(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	_, err := s.BalanceQuery(balanceQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RespondWithAPIError(mpesatest.BalancePath, &mpesa.APIError{
+		StatusCode:   http.StatusUnauthorized,
+		ErrorCode:    "404.001.03",
+		ErrorMessage: "Invalid Access Token",
+	})
+	_, err = s.BalanceQuery(balanceQuery())
+	if !errors.Is(err, mpesa.ErrInvalidAccessToken) {
+		t.Fatalf("expected ErrInvalidAccessToken, got %v", err)
+	}
+	_, err = s.BalanceQuery(balanceQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(mpesatest.OAuthPath)); n != 2 {
+		t.Fatalf("expected a new oauth call after the 401, got %d calls", n)
+	}
+}
+
+func TestSharedTokenStoreKeepsHostsApart(t *testing.T) {
+	fake := mpesatest.NewServer()
+	defer fake.Close()
+	other := mpesatest.NewServer()
+	other.AccessToken = "other-access-token"
+	defer other.Close()
+
+	store := mpesa.NewMemoryTokenStore()
+	for _, srv := range []*mpesatest.Server{fake, other} {
+		config := srv.Config()
+		config.TokenStore = store
+		s, err := mpesa.NewMpesa(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := s.AccessToken()
+		if err != nil || token != srv.AccessToken {
+			t.Fatalf("expected the token of %s, got %q, %v", srv.URL, token, err)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package mpesatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+//DefaultCallbackDelay is how long after accepting a request the server posts its result
+const DefaultCallbackDelay = 10 * time.Millisecond
+
+//Result scripts the asynchronous outcome of a request
+type Result struct {
+	//ResultCode optional defaults to "0" (success), numeric codes are sent as json numbers
+	//and others (e.g. reversal "R000002") as strings
+	ResultCode string
+	//ResultDesc optional defaults to a description matching the code
+	ResultDesc string
+	//Delay optional overrides Server.CallbackDelay
+	Delay time.Duration
+	//Drop completes the transaction without posting the callback, like a callback lost by daraja
+	Drop bool
+}
+
+func (r *Result) success() bool {
+	return r.ResultCode == "" || r.ResultCode == "0"
+}
+
+func (r *Result) code() interface{} {
+	if r.ResultCode == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(r.ResultCode); err == nil {
+		return n
+	}
+	return r.ResultCode
+}
+
+func (r *Result) desc() string {
+	if r.ResultDesc != "" {
+		return r.ResultDesc
+	}
+	if r.success() {
+		return "The service request is processed successfully."
+	}
+	return "The service request failed."
+}
+
+//Callback is a result callback posted by the server
+type Callback struct {
+	URL  string
+	Body []byte
+	//StatusCode returned by the callback url, 0 if the post failed
+	StatusCode int
+	//Response is the body returned by the callback url
+	Response []byte
+	Err      error
+}
+
+//Decode decodes the json body of the callback into v
+func (c *Callback) Decode(v interface{}) error {
+	return json.Unmarshal(c.Body, v)
+}
+
+//EnqueueResult scripts the outcome of the next accepted request on path,
+//requests without a scripted result succeed
+func (s *Server) EnqueueResult(path string, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[path] = append(s.results[path], result)
+}
+
+//Callbacks returns the callbacks posted so far
+func (s *Server) Callbacks() []*Callback {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Callback(nil), s.callbacks...)
+}
+
+//WaitCallbacks blocks until all scheduled callbacks have been posted
+func (s *Server) WaitCallbacks() {
+	s.pending.Wait()
+}
+
+func (s *Server) nextResult(path string) *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.results[path]
+	if len(queue) == 0 {
+		return &Result{}
+	}
+	s.results[path] = queue[1:]
+	return queue[0]
+}
+
+type stkState struct {
+	merchantRequestID string
+	result            *Result
+	done              bool
+}
+
+//serveSTKQuery answers from the state of the stk push, pending pushes get the
+//same error daraja returns while the customer has not responded
+func (s *Server) serveSTKQuery(w http.ResponseWriter, payload map[string]interface{}) {
+	checkoutRequestID, _ := payload["CheckoutRequestID"].(string)
+	s.mu.Lock()
+	state, ok := s.stkPushes[checkoutRequestID]
+	var done bool
+	if ok {
+		done = state.done
+	}
+	s.mu.Unlock()
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid CheckoutRequestID")
+		return
+	}
+	if !done {
+		s.writeError(w, http.StatusInternalServerError, "500.001.1001", "The transaction is being processed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"MerchantRequestID":   state.merchantRequestID,
+		"CheckoutRequestID":   checkoutRequestID,
+		"ResponseCode":        "0",
+		"ResponseDescription": "The service request has been accepted successsfully",
+		"ResultCode":          fmt.Sprint(state.result.code()),
+		"ResultDesc":          state.result.desc(),
+	})
+}
+
+//scheduleCallback posts the result of an accepted request to the url the client sent
+func (s *Server) scheduleCallback(path string, payload, resBody map[string]interface{}) {
+	var url string
+	var body interface{}
+	result := s.nextResult(path)
+	switch path {
+	case STKPushPath:
+		url, _ = payload["CallBackURL"].(string)
+		checkoutRequestID, _ := resBody["CheckoutRequestID"].(string)
+		merchantRequestID, _ := resBody["MerchantRequestID"].(string)
+		s.mu.Lock()
+		s.stkPushes[checkoutRequestID] = &stkState{
+			merchantRequestID: merchantRequestID,
+			result:            result,
+		}
+		s.mu.Unlock()
+		body = s.stkCallback(payload, resBody, result)
+	case B2CPath, B2CV3Path, B2BPath, ReversalPath, BalancePath, TransactionStatusPath:
+		url, _ = payload["ResultURL"].(string)
+		body = s.resultCallback(path, payload, resBody, result)
+	case C2BRegisterURLPath:
+		s.registerC2BURLs(payload)
+		return
+	case C2BSimulatePath:
+		s.scheduleC2B(payload, result)
+		return
+	default:
+		return
+	}
+	delay := s.CallbackDelay
+	if result.Delay > 0 {
+		delay = result.Delay
+	}
+	s.pending.Add(1)
+	time.AfterFunc(delay, func() {
+		defer s.pending.Done()
+		if path == STKPushPath {
+			s.mu.Lock()
+			//the push is gone if the server was reset meanwhile
+			if state, ok := s.stkPushes[resBody["CheckoutRequestID"].(string)]; ok {
+				state.done = true
+			}
+			s.mu.Unlock()
+		}
+		if result.Drop {
+			return
+		}
+		s.postCallback(url, body)
+	})
+}
+
+func (s *Server) postCallback(url string, body interface{}) *Callback {
+	data, _ := json.Marshal(body)
+	callback := &Callback{URL: url, Body: data}
+	client := s.CallbackClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		callback.Err = err
+	} else {
+		callback.Response, _ = ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		callback.StatusCode = res.StatusCode
+	}
+	s.mu.Lock()
+	s.callbacks = append(s.callbacks, callback)
+	s.mu.Unlock()
+	return callback
+}
+
+//nairobi is the timezone of daraja timestamps
+var nairobi = time.FixedZone("EAT", 3*60*60)
+
+func (s *Server) transactionID() string {
+	return fmt.Sprintf("MPT%07d", s.nextID())
+}
+
+type item struct {
+	Name  string
+	Value interface{} `json:",omitempty"`
+}
+
+func (s *Server) stkCallback(payload, resBody map[string]interface{}, result *Result) interface{} {
+	callback := map[string]interface{}{
+		"MerchantRequestID": resBody["MerchantRequestID"],
+		"CheckoutRequestID": resBody["CheckoutRequestID"],
+		"ResultCode":        result.code(),
+		"ResultDesc":        result.desc(),
+	}
+	if result.success() {
+		callback["CallbackMetadata"] = map[string]interface{}{
+			"Item": []item{
+				{"Amount", json.Number(fmt.Sprint(payload["Amount"]))},
+				{"MpesaReceiptNumber", s.transactionID()},
+				{"Balance", nil},
+				{"TransactionDate", json.Number(time.Now().In(nairobi).Format("20060102150405"))},
+				{"PhoneNumber", json.Number(fmt.Sprint(payload["PhoneNumber"]))},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"Body": map[string]interface{}{
+			"stkCallback": callback,
+		},
+	}
+}
+
+type parameter struct {
+	Key   string
+	Value interface{}
+}
+
+func (s *Server) resultCallback(path string, payload, resBody map[string]interface{}, result *Result) interface{} {
+	now := time.Now().In(nairobi)
+	transactionID := s.transactionID()
+	var parameters []parameter
+	switch path {
+	case B2CPath, B2CV3Path:
+		parameters = []parameter{
+			{"TransactionAmount", json.Number(fmt.Sprint(payload["Amount"]))},
+			{"TransactionReceipt", transactionID},
+			{"B2CRecipientIsRegisteredCustomer", "Y"},
+			{"B2CChargesPaidAccountAvailableFunds", json.Number("-4510.00")},
+			{"ReceiverPartyPublicName", fmt.Sprint(payload["PartyB"]) + " - John Doe"},
+			{"TransactionCompletedDateTime", now.Format("02.01.2006 15:04:05")},
+			{"B2CUtilityAccountAvailableFunds", json.Number("10116.00")},
+			{"B2CWorkingAccountAvailableFunds", json.Number("900000.00")},
+		}
+	case B2BPath:
+		parameters = []parameter{
+			{"InitiatorAccountCurrentBalance", "{ Amount={BasicAmount=46713.00, MinimumAmount=4671300, CurrencyCode=KES}}"},
+			{"DebitAccountBalance", "{Amount={CurrencyCode=KES, MinimumAmount=618683, BasicAmount=6186.83}}"},
+			{"Amount", json.Number(fmt.Sprint(payload["Amount"]))},
+			{"DebitPartyAffectedAccountBalance", "Working Account|KES|346568.83|6186.83|340382.00|0.00"},
+			{"TransCompletedTime", json.Number(now.Format("20060102150405"))},
+			{"DebitPartyCharges", ""},
+			{"ReceiverPartyPublicName", fmt.Sprint(payload["PartyB"]) + " - Supplier Ltd"},
+			{"Currency", "KES"},
+		}
+	case ReversalPath:
+		parameters = []parameter{
+			{"DebitAccountBalance", "Utility Account|KES|346237.00|346237.00|0.00|0.00"},
+			{"Amount", json.Number(fmt.Sprint(payload["Amount"]))},
+			{"TransCompletedTime", json.Number(now.Format("20060102150405"))},
+			{"OriginalTransactionID", payload["TransactionID"]},
+			{"Charge", json.Number("0.00")},
+			{"CreditPartyPublicName", fmt.Sprint(payload["ReceiverParty"]) + " - John Doe"},
+			{"DebitPartyPublicName", "600992 - Safaricom Daraja 992"},
+		}
+	case BalancePath:
+		parameters = []parameter{
+			{"AccountBalance", "Working Account|KES|700000.00|700000.00|0.00|0.00&Float Account|KES|0.00|0.00|0.00|0.00&Utility Account|KES|228037.00|228037.00|0.00|0.00&Charges Paid Account|KES|-1540.00|-1540.00|0.00|0.00&Organization Settlement Account|KES|0.00|0.00|0.00|0.00"},
+			{"BOCompletedTime", json.Number(now.Format("20060102150405"))},
+		}
+	case TransactionStatusPath:
+		parameters = []parameter{
+			{"DebitPartyName", fmt.Sprint(payload["PartyA"]) + " - Safaricom Daraja"},
+			{"CreditPartyName", "254708374149 - John Doe"},
+			{"OriginatorConversationID", resBody["OriginatorConversationID"]},
+			{"InitiatedTime", json.Number(now.Add(-time.Minute).Format("20060102150405"))},
+			{"DebitAccountType", "Utility Account"},
+			{"DebitPartyCharges", ""},
+			{"TransactionReason", ""},
+			{"ReasonType", "Business Payment to Customer via API"},
+			{"TransactionStatus", "Completed"},
+			{"FinalisedTime", json.Number(now.Format("20060102150405"))},
+			{"Amount", json.Number("10.00")},
+			{"ConversationID", resBody["ConversationID"]},
+			{"ReceiptNo", payload["TransactionID"]},
+		}
+	}
+	res := map[string]interface{}{
+		"ResultType":               0,
+		"ResultCode":               result.code(),
+		"ResultDesc":               result.desc(),
+		"OriginatorConversationID": resBody["OriginatorConversationID"],
+		"ConversationID":           resBody["ConversationID"],
+		"TransactionID":            transactionID,
+		"ReferenceData": map[string]interface{}{
+			"ReferenceItem": parameter{"QueueTimeoutURL", payload["QueueTimeOutURL"]},
+		},
+	}
+	if occasion, _ := payload["Occassion"].(string); occasion != "" {
+		res["ReferenceData"] = map[string]interface{}{
+			"ReferenceItem": []parameter{
+				{"Occasion", occasion},
+				{"QueueTimeoutURL", payload["QueueTimeOutURL"]},
+			},
+		}
+	}
+	if path == B2BPath {
+		res["ReferenceData"] = map[string]interface{}{
+			"ReferenceItem": []parameter{
+				{"BillReferenceNumber", payload["AccountReference"]},
+				{"QueueTimeoutURL", payload["QueueTimeOutURL"]},
+			},
+		}
+	}
+	if result.success() {
+		res["ResultParameters"] = map[string]interface{}{
+			"ResultParameter": parameters,
+		}
+	}
+	return map[string]interface{}{
+		"Result": res,
+	}
+}
+
+type c2bURLs struct {
+	validationURL   string
+	confirmationURL string
+	responseType    string
+}
+
+func (s *Server) registerC2BURLs(payload map[string]interface{}) {
+	urls := &c2bURLs{}
+	urls.validationURL, _ = payload["ValidationURL"].(string)
+	urls.confirmationURL, _ = payload["ConfirmationURL"].(string)
+	urls.responseType, _ = payload["ResponseType"].(string)
+	s.mu.Lock()
+	s.c2bURLs[fmt.Sprint(payload["ShortCode"])] = urls
+	s.mu.Unlock()
+}
+
+//scheduleC2B posts a simulated payment to the urls registered for its short code,
+//like daraja the confirmation is skipped when validation rejects the payment or
+//fails with ResponseType Cancelled
+func (s *Server) scheduleC2B(payload map[string]interface{}, result *Result) {
+	s.mu.Lock()
+	urls, ok := s.c2bURLs[fmt.Sprint(payload["ShortCode"])]
+	s.mu.Unlock()
+	if !ok || result.Drop {
+		return
+	}
+	transaction := map[string]interface{}{
+		"TransactionType":   "Pay Bill",
+		"TransID":           s.transactionID(),
+		"TransTime":         time.Now().In(nairobi).Format("20060102150405"),
+		"TransAmount":       fmt.Sprint(payload["Amount"]),
+		"BusinessShortCode": fmt.Sprint(payload["ShortCode"]),
+		"BillRefNumber":     payload["BillRefNumber"],
+		"InvoiceNumber":     "",
+		"OrgAccountBalance": "",
+		"ThirdPartyTransID": "",
+		"MSISDN":            fmt.Sprint(payload["Msisdn"]),
+		"FirstName":         "John",
+		"MiddleName":        "",
+		"LastName":          "Doe",
+	}
+	if payload["CommandID"] == mpesa.CustomerBuyGoodsOnline {
+		transaction["TransactionType"] = "Buy Goods"
+	}
+	delay := s.CallbackDelay
+	if result.Delay > 0 {
+		delay = result.Delay
+	}
+	s.pending.Add(1)
+	time.AfterFunc(delay, func() {
+		defer s.pending.Done()
+		if urls.validationURL != "" {
+			callback := s.postCallback(urls.validationURL, transaction)
+			ack := &mpesa.C2BAck{}
+			failed := callback.StatusCode != http.StatusOK || json.Unmarshal(callback.Response, ack) != nil
+			if failed && urls.responseType == mpesa.CancelResponseType {
+				return
+			}
+			if !failed && ack.ResultCode != "0" {
+				return
+			}
+		}
+		if urls.confirmationURL != "" {
+			transaction["OrgAccountBalance"] = "49197.00"
+			s.postCallback(urls.confirmationURL, transaction)
+		}
+	})
+}
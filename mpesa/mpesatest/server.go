@@ -0,0 +1,424 @@
+//Package mpesatest provides an in process fake of the daraja api for tests.
+//
+//The server implements the oauth, express, c2b, b2c, b2b, reversal, balance and
+//transaction status endpoints with the payload shapes sent by package mpesa,
+//records every request it receives and can be scripted to return specific
+//response codes or api errors.
+//
+//Like daraja, accepted requests are followed by an asynchronous result posted to
+//the CallBackURL/ResultURL of the request, see Server.EnqueueResult. Simulated c2b
+//payments are posted to the validation and confirmation urls registered for their
+//short code.
+package mpesatest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+//OAuthPath oauth endpoint
+const OAuthPath string = "/oauth/v1/generate"
+
+//STKPushPath express stk push endpoint
+const STKPushPath string = "/mpesa/stkpush/v1/processrequest"
+
+//STKQueryPath express transaction status endpoint
+const STKQueryPath string = "/mpesa/stkpushquery/v1/query"
+
+//C2BRegisterURLPath c2b register url endpoint
+const C2BRegisterURLPath string = "/mpesa/c2b/v1/registerurl"
+
+//C2BSimulatePath c2b simulate endpoint
+const C2BSimulatePath string = "/mpesa/c2b/v1/simulate"
+
+//B2CPath b2c payment request endpoint
+const B2CPath string = "/mpesa/b2c/v1/paymentrequest"
+
+//B2CV3Path b2c payment request endpoint used when an OriginatorConversationID is set
+const B2CV3Path string = "/mpesa/b2c/v3/paymentrequest"
+
+//B2BPath b2b payment request endpoint
+const B2BPath string = "/mpesa/b2b/v1/paymentrequest"
+
+//ReversalPath reversal endpoint
+const ReversalPath string = "/mpesa/reversal/v1/request"
+
+//BalancePath account balance endpoint
+const BalancePath string = "/mpesa/accountbalance/v1/query"
+
+//TransactionStatusPath transaction status endpoint
+const TransactionStatusPath string = "/mpesa/transactionstatus/v1/query"
+
+//DefaultAccessToken is the access token issued by the fake oauth endpoint
+const DefaultAccessToken string = "mpesatest-access-token"
+
+//Request is a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+//Decode decodes the json body of the request into v
+func (r *Request) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+//Response is a scripted response for an endpoint
+type Response struct {
+	//StatusCode optional defaults to 200
+	StatusCode int
+	//ResponseCode & ResponseDescription optional override the fields of the default body
+	ResponseCode        string
+	ResponseDescription string
+	//Body optional replaces the default body, it is encoded as json
+	Body interface{}
+}
+
+//Server is a fake daraja api
+type Server struct {
+	*httptest.Server
+	//ConsumerKey & ConsumerSecret accepted by the oauth endpoint
+	ConsumerKey    string
+	ConsumerSecret string
+	//AccessToken issued by the oauth endpoint and required by the api endpoints
+	AccessToken string
+	//ExpiresIn is the lifetime in seconds reported for issued tokens
+	ExpiresIn int
+	//CallbackDelay is how long after accepting a request its result is posted
+	CallbackDelay time.Duration
+	//CallbackClient optional is used to post results
+	CallbackClient *http.Client
+
+	mu        sync.Mutex
+	requests  []*Request
+	scripted  map[string][]*Response
+	results   map[string][]*Result
+	callbacks []*Callback
+	stkPushes map[string]*stkState
+	c2bURLs   map[string]*c2bURLs
+	pending   sync.WaitGroup
+	seq       int
+	//originators are the OriginatorConversationIDs received on B2CV3Path
+	originators map[string]bool
+}
+
+//NewServer starts and returns a *Server, call Close when done
+func NewServer() *Server {
+	s := &Server{
+		ConsumerKey:    "mpesatest-consumer-key",
+		ConsumerSecret: "mpesatest-consumer-secret",
+		AccessToken:    DefaultAccessToken,
+		ExpiresIn:      3599,
+		CallbackDelay:  DefaultCallbackDelay,
+		scripted:       map[string][]*Response{},
+		results:        map[string][]*Result{},
+		stkPushes:      map[string]*stkState{},
+		c2bURLs:        map[string]*c2bURLs{},
+		originators:    map[string]bool{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+//Config returns an *mpesa.Config pointing at the server
+func (s *Server) Config() *mpesa.Config {
+	return &mpesa.Config{
+		ConsumerKey:    s.ConsumerKey,
+		ConsumerSecret: s.ConsumerSecret,
+		Environment:    mpesa.SandBox,
+		BaseURL:        s.URL,
+	}
+}
+
+//Requests returns the requests received on path, all requests if path is empty
+func (s *Server) Requests(path string) (requests []*Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.requests {
+		if path == "" || r.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return
+}
+
+//LastRequest returns the last request received on path or nil
+func (s *Server) LastRequest(path string) *Request {
+	requests := s.Requests(path)
+	if len(requests) == 0 {
+		return nil
+	}
+	return requests[len(requests)-1]
+}
+
+//Enqueue scripts the next response of path, queued responses are used once in order
+func (s *Server) Enqueue(path string, res *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripted[path] = append(s.scripted[path], res)
+}
+
+//RespondWithCode scripts the next response of path to carry responseCode
+func (s *Server) RespondWithCode(path, responseCode, description string) {
+	s.Enqueue(path, &Response{
+		ResponseCode:        responseCode,
+		ResponseDescription: description,
+	})
+}
+
+//RespondWithAPIError scripts the next response of path to be apiErr,
+//apiErr.StatusCode defaults to 500
+func (s *Server) RespondWithAPIError(path string, apiErr *mpesa.APIError) {
+	statusCode := apiErr.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	s.Enqueue(path, &Response{
+		StatusCode: statusCode,
+		Body:       errorBody(apiErr.RequestID, apiErr.ErrorCode, apiErr.ErrorMessage),
+	})
+}
+
+//Reset forgets received requests, posted callbacks, scripted responses & results,
+//stk pushes, registered c2b urls and received OriginatorConversationIDs.
+//Callbacks already scheduled are still posted, call WaitCallbacks first to avoid them.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.callbacks = nil
+	s.scripted = map[string][]*Response{}
+	s.results = map[string][]*Result{}
+	s.stkPushes = map[string]*stkState{}
+	s.c2bURLs = map[string]*c2bURLs{}
+	s.originators = map[string]bool{}
+}
+
+func (s *Server) nextID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return s.seq
+}
+
+func (s *Server) nextScripted(path string) *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.scripted[path]
+	if len(queue) == 0 {
+		return nil
+	}
+	s.scripted[path] = queue[1:]
+	return queue[0]
+}
+
+func errorBody(requestID, errorCode, errorMessage string) map[string]string {
+	return map[string]string{
+		"requestId":    requestID,
+		"errorCode":    errorCode,
+		"errorMessage": errorMessage,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, statusCode int, errorCode, errorMessage string) {
+	requestID := fmt.Sprintf("mpesatest-%d", s.nextID())
+	writeJSON(w, statusCode, errorBody(requestID, errorCode, errorMessage))
+}
+
+//requiredFields lists the payload fields each endpoint rejects when empty
+var requiredFields = map[string][]string{
+	STKPushPath:           {"BusinessShortCode", "Password", "Timestamp", "TransactionType", "Amount", "PartyA", "PartyB", "PhoneNumber", "CallBackURL", "AccountReference"},
+	STKQueryPath:          {"BusinessShortCode", "Password", "Timestamp", "CheckoutRequestID"},
+	C2BRegisterURLPath:    {"ShortCode", "ResponseType"},
+	C2BSimulatePath:       {"ShortCode", "CommandID", "Amount", "Msisdn"},
+	B2CPath:               {"InitiatorName", "SecurityCredential", "CommandID", "Amount", "PartyA", "PartyB", "QueueTimeOutURL", "ResultURL"},
+	B2CV3Path:             {"OriginatorConversationID", "InitiatorName", "SecurityCredential", "CommandID", "Amount", "PartyA", "PartyB", "QueueTimeOutURL", "ResultURL"},
+	B2BPath:               {"Initiator", "SecurityCredential", "CommandID", "SenderIdentifierType", "RecieverIdentifierType", "Amount", "PartyA", "PartyB", "QueueTimeOutURL", "ResultURL"},
+	ReversalPath:          {"Initiator", "SecurityCredential", "CommandID", "TransactionID", "Amount", "ReceiverParty", "RecieverIdentifierType", "QueueTimeOutURL", "ResultURL"},
+	BalancePath:           {"Initiator", "SecurityCredential", "CommandID", "PartyA", "IdentifierType", "QueueTimeOutURL", "ResultURL"},
+	TransactionStatusPath: {"Initiator", "SecurityCredential", "CommandID", "TransactionID", "PartyA", "IdentifierType", "QueueTimeOutURL", "ResultURL"},
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Unreadable body")
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	s.mu.Unlock()
+
+	if r.URL.Path == OAuthPath {
+		s.serveOAuth(w, r)
+		return
+	}
+	if _, ok := requiredFields[r.URL.Path]; !ok {
+		s.writeError(w, http.StatusNotFound, "404.002.01", "Resource not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "405.001.01", "Method not allowed")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.AccessToken {
+		s.writeError(w, http.StatusUnauthorized, "404.001.03", "Invalid Access Token")
+		return
+	}
+	//keep numbers exactly as sent so large amounts are not reformatted e.g. 1e+06
+	payload := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err = decoder.Decode(&payload)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid JSON payload")
+		return
+	}
+	for _, field := range requiredFields[r.URL.Path] {
+		if v, ok := payload[field]; !ok || v == nil || v == "" {
+			s.writeError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid "+field)
+			return
+		}
+	}
+	res := s.nextScripted(r.URL.Path)
+	if res != nil && res.Body != nil {
+		statusCode := res.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		writeJSON(w, statusCode, res.Body)
+		return
+	}
+	if r.URL.Path == STKQueryPath && res == nil {
+		s.serveSTKQuery(w, payload)
+		return
+	}
+	if r.URL.Path == B2CV3Path && s.duplicateOriginator(payload) {
+		s.writeError(w, http.StatusInternalServerError, "500.001.1001", "Duplicate OriginatorConversationID")
+		return
+	}
+	resBody := s.defaultBody(r.URL.Path, payload)
+	statusCode := http.StatusOK
+	if res != nil {
+		if res.StatusCode != 0 {
+			statusCode = res.StatusCode
+		}
+		if res.ResponseCode != "" {
+			resBody["ResponseCode"] = res.ResponseCode
+		}
+		if res.ResponseDescription != "" {
+			resBody["ResponseDescription"] = res.ResponseDescription
+		}
+	}
+	writeJSON(w, statusCode, resBody)
+	if statusCode == http.StatusOK && resBody["ResponseCode"] == "0" {
+		s.scheduleCallback(r.URL.Path, payload, resBody)
+	}
+}
+
+func (s *Server) serveOAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "405.001.01", "Method not allowed")
+		return
+	}
+	if r.URL.Query().Get("grant_type") != "client_credentials" {
+		s.writeError(w, http.StatusBadRequest, "400.008.02", "Invalid grant type passed")
+		return
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(s.ConsumerKey + ":" + s.ConsumerSecret))
+	if r.Header.Get("Authorization") != "Basic "+credentials {
+		s.writeError(w, http.StatusBadRequest, "400.008.01", "Invalid Authentication passed")
+		return
+	}
+	res := s.nextScripted(OAuthPath)
+	if res != nil && res.Body != nil {
+		statusCode := res.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		writeJSON(w, statusCode, res.Body)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": s.AccessToken,
+		"expires_in":   fmt.Sprint(s.ExpiresIn),
+	})
+}
+
+//duplicateOriginator records the OriginatorConversationID of payload, it is
+//true when the id was already received
+func (s *Server) duplicateOriginator(payload map[string]interface{}) bool {
+	id := fmt.Sprint(payload["OriginatorConversationID"])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.originators[id] {
+		return true
+	}
+	s.originators[id] = true
+	return false
+}
+
+//defaultBody returns the successful synchronous response of path
+func (s *Server) defaultBody(path string, payload map[string]interface{}) map[string]interface{} {
+	id := s.nextID()
+	conversationID := fmt.Sprintf("AG_20191219_%020d", id)
+	originatorConversationID := fmt.Sprintf("%d-%d-1", 10000+id, 20000+id)
+	if path == B2CV3Path {
+		originatorConversationID = fmt.Sprint(payload["OriginatorConversationID"])
+	}
+	switch path {
+	case STKPushPath:
+		return map[string]interface{}{
+			"MerchantRequestID":   fmt.Sprintf("%d-%d-1", 30000+id, 40000+id),
+			"CheckoutRequestID":   fmt.Sprintf("ws_CO_191220191020363925_%d", id),
+			"ResponseCode":        "0",
+			"ResponseDescription": "Success. Request accepted for processing",
+			"CustomerMessage":     "Success. Request accepted for processing",
+		}
+	case STKQueryPath:
+		return map[string]interface{}{
+			"MerchantRequestID":   fmt.Sprintf("%d-%d-1", 30000+id, 40000+id),
+			"CheckoutRequestID":   payload["CheckoutRequestID"],
+			"ResponseCode":        "0",
+			"ResponseDescription": "The service request has been accepted successsfully",
+			"ResultCode":          "0",
+			"ResultDesc":          "The service request is processed successfully.",
+		}
+	case C2BRegisterURLPath, C2BSimulatePath:
+		//daraja misspells OriginatorCoversationID on the c2b endpoints
+		return map[string]interface{}{
+			"OriginatorCoversationID": originatorConversationID,
+			"ConversationID":          conversationID,
+			"ResponseCode":            "0",
+			"ResponseDescription":     "Accept the service request successfully.",
+		}
+	}
+	return map[string]interface{}{
+		"OriginatorConversationID": originatorConversationID,
+		"ConversationID":           conversationID,
+		"ResponseCode":             "0",
+		"ResponseDescription":      "Accept the service request successfully.",
+	}
+}
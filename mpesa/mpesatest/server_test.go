@@ -0,0 +1,126 @@
+package mpesatest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+func TestResetForgetsState(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	var posts int32
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		w.Write([]byte(`{"ResultCode":"0","ResultDesc":"Accepted"}`))
+	}))
+	defer callbacks.Close()
+	s, err := mpesa.NewMpesa(srv.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b2c := func() error {
+		_, err := s.B2C(&mpesa.B2C{
+			InitiatorUserName:        "testapi",
+			InitiatorPassword:        "Safaricom007@",
+			ShortCode:                "600000",
+			PhoneNumber:              "0712345678",
+			Amount:                   mpesa.KES(100),
+			ResultCallBackURL:        callbacks.URL,
+			OriginatorConversationID: "payout-1",
+		})
+		return err
+	}
+	if err = b2c(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.RegisterURLs(&mpesa.RegisterURLs{
+		ShortCode:       "600000",
+		ValidationURL:   callbacks.URL,
+		ConfirmationURL: callbacks.URL,
+		ResponseType:    mpesa.CompletedResponseType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.WaitCallbacks()
+	srv.Reset()
+	atomic.StoreInt32(&posts, 0)
+
+	if err = b2c(); err != nil {
+		t.Fatalf("expected the OriginatorConversationID to be forgotten, got %v", err)
+	}
+	srv.WaitCallbacks()
+	atomic.StoreInt32(&posts, 0)
+	_, err = s.C2BSimulate(&mpesa.C2BSimulate{ShortCode: "600000", Amount: mpesa.KES(10), Msisdn: "0712345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.WaitCallbacks()
+	if n := atomic.LoadInt32(&posts); n != 0 {
+		t.Fatalf("expected no c2b posts to urls registered before Reset, got %d", n)
+	}
+}
+
+func TestLargeAmountsKeptExactly(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	s, err := mpesa.NewMpesa(srv.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversals := make(chan *mpesa.ReversalRes, 1)
+	results := httptest.NewServer(mpesa.NewReversalResultHandler(func(ctx context.Context, res *mpesa.ReversalRes) error {
+		reversals <- res
+		return nil
+	}, nil))
+	defer results.Close()
+	transactions := make(chan *mpesa.C2BTransaction, 1)
+	c2b := httptest.NewServer(mpesa.NewC2BConfirmationHandler(func(ctx context.Context, t *mpesa.C2BTransaction) error {
+		transactions <- t
+		return nil
+	}))
+	defer c2b.Close()
+
+	_, err = s.Reverse(&mpesa.Reversal{
+		TransactionID:     "LKXXXX1234",
+		InitiatorUserName: "testapi",
+		InitiatorPassword: "Safaricom007@",
+		ShortCode:         "600000",
+		Amount:            mpesa.KES(1000000),
+		ResultCallBackURL: results.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.RegisterURLs(&mpesa.RegisterURLs{ShortCode: "600000", ConfirmationURL: c2b.URL, ResponseType: mpesa.CompletedResponseType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.C2BSimulate(&mpesa.C2BSimulate{ShortCode: "600000", Amount: mpesa.Cents(200000050), Msisdn: "0712345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.WaitCallbacks()
+	select {
+	case res := <-reversals:
+		if res.Amount != mpesa.KES(1000000) {
+			t.Fatalf("expected KES 1000000, got %v", res.Amount)
+		}
+	default:
+		t.Fatal("reversal result not delivered")
+	}
+	select {
+	case transaction := <-transactions:
+		if transaction.TransAmount != mpesa.Cents(200000050) {
+			t.Fatalf("expected KES 2000000.50, got %v", transaction.TransAmount)
+		}
+	default:
+		t.Fatal("c2b confirmation not delivered")
+	}
+}
@@ -0,0 +1,278 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//ResultParameter is a key value pair of a result callback
+type ResultParameter struct {
+	Key   string      `json:"Key"`
+	Value interface{} `json:"Value"`
+}
+
+//ResultParameters is a list of result parameters, daraja sends a single
+//parameter as an object instead of a list
+type ResultParameters []ResultParameter
+
+//UnmarshalJSON decodes a list of parameters or a single parameter,
+//values are decoded with json.Number
+func (p *ResultParameters) UnmarshalJSON(data []byte) (err error) {
+	data = bytes.TrimSpace(data)
+	//the decoder options of the caller do not reach custom unmarshalers
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if len(data) > 0 && data[0] == '{' {
+		var param ResultParameter
+		err = decoder.Decode(&param)
+		*p = ResultParameters{param}
+		return
+	}
+	var params []ResultParameter
+	err = decoder.Decode(&params)
+	*p = params
+	return
+}
+
+//ResultCallBackResponse is the payload daraja posts to the ResultURL of
+//b2c, b2b, reversal, balance and transaction status requests
+type ResultCallBackResponse struct {
+	Result struct {
+		ResultType int `json:"ResultType"`
+		//ResultCode is a number for most apis and a string e.g. "R000002" for reversals
+		ResultCode               interface{} `json:"ResultCode"`
+		ResultDesc               string      `json:"ResultDesc"`
+		OriginatorConversationID string      `json:"OriginatorConversationID"`
+		ConversationID           string      `json:"ConversationID"`
+		TransactionID            string      `json:"TransactionID"`
+		ResultParameters         *struct {
+			ResultParameter ResultParameters `json:"ResultParameter"`
+		} `json:"ResultParameters"`
+		ReferenceData *struct {
+			ReferenceItem ResultParameters `json:"ReferenceItem"`
+		} `json:"ReferenceData"`
+	} `json:"Result"`
+}
+
+//Result is the parsed form of ResultCallBackResponse, parameter values are
+//decoded with json.Number so amounts are kept exactly as sent
+type Result struct {
+	ResultType int
+	//RawResultCode is the result code exactly as sent, see Code()
+	RawResultCode            string
+	ResultDesc               string
+	OriginatorConversationID string
+	ConversationID           string
+	TransactionID            string
+	//Parameters empty unless sent by daraja, usually only on success
+	Parameters ResultParameters
+	//ReferenceData e.g. QueueTimeoutURL or the Occasion of a b2c payment
+	ReferenceData ResultParameters
+}
+
+//Code returns the typed result code, ResultCodeUnknown for non numeric codes
+func (r *Result) Code() ResultCode {
+	return ParseResultCode(r.RawResultCode)
+}
+
+//Param returns the value of the result parameter key
+func (r *Result) Param(key string) (value interface{}, ok bool) {
+	return r.Parameters.get(key)
+}
+
+//Reference returns the value of the reference item key
+func (r *Result) Reference(key string) (value interface{}, ok bool) {
+	return r.ReferenceData.get(key)
+}
+
+func (p ResultParameters) get(key string) (value interface{}, ok bool) {
+	for _, param := range p {
+		if param.Key == key {
+			return param.Value, true
+		}
+	}
+	return
+}
+
+//paramString returns the parameter key as a string, empty when missing
+func (r *Result) paramString(key string) string {
+	value, ok := r.Param(key)
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+//paramAmount parses the parameter key as an Amount, zero when missing
+func (r *Result) paramAmount(key string) (a Amount, err error) {
+	value, _ := r.Param(key)
+	a, err = amountValue(value)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", key, err)
+	}
+	return
+}
+
+//paramAmounts parses the parameter of each key into its Amount, zero when missing
+func (r *Result) paramAmounts(amounts map[string]*Amount) (err error) {
+	for key, a := range amounts {
+		*a, err = r.paramAmount(key)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//paramTime parses the parameter key with layout, zero when missing
+func (r *Result) paramTime(key, layout string) (t time.Time, err error) {
+	value := r.paramString(key)
+	if value == "" {
+		return
+	}
+	return parseTime(value, layout)
+}
+
+//validate checks the fields every result callback carries
+func (r *Result) validate() (err error) {
+	if r.ConversationID == "" && r.OriginatorConversationID == "" {
+		err = fmt.Errorf("Missing ConversationID and OriginatorConversationID")
+		return
+	}
+	if r.RawResultCode == "" {
+		err = fmt.Errorf("Missing ResultCode")
+	}
+	return
+}
+
+//ParseResult parses the payload posted to a ResultURL
+func (s *Mpesa) ParseResult(resultCallBackRes io.Reader) (result *Result, err error) {
+	return parseResult(resultCallBackRes)
+}
+
+func parseResult(resultCallBackRes io.Reader) (result *Result, err error) {
+	data, err := ioutil.ReadAll(resultCallBackRes)
+	if err != nil {
+		return
+	}
+	res := ResultCallBackResponse{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&res)
+	if err != nil {
+		return
+	}
+	result = &Result{
+		ResultType:               res.Result.ResultType,
+		ResultDesc:               res.Result.ResultDesc,
+		OriginatorConversationID: res.Result.OriginatorConversationID,
+		ConversationID:           res.Result.ConversationID,
+		TransactionID:            res.Result.TransactionID,
+	}
+	if res.Result.ResultCode != nil {
+		result.RawResultCode = fmt.Sprint(res.Result.ResultCode)
+	}
+	if res.Result.ResultParameters != nil {
+		result.Parameters = res.Result.ResultParameters.ResultParameter
+	}
+	if res.Result.ReferenceData != nil {
+		result.ReferenceData = res.Result.ReferenceData.ReferenceItem
+	}
+	return
+}
+
+//readResult reads, parses and validates the result callback of r,
+//on failure it responds to the request and ok is false
+func readResult(w http.ResponseWriter, r *http.Request, maxBytes int64) (result *Result, ok bool) {
+	body, ok := readCallback(w, r, maxBytes)
+	if !ok {
+		return
+	}
+	result, err := parseResult(bytes.NewReader(body))
+	if err == nil {
+		err = result.validate()
+	}
+	if err != nil {
+		ok = false
+		rejectCallback(w, http.StatusBadRequest, "Malformed result: "+err.Error())
+	}
+	return
+}
+
+//resultCoder is a parsed result telling its outcome
+type resultCoder interface {
+	Code() ResultCode
+}
+
+//serveResult reads the result callback of r, converts it with parse and passes it to
+//onSuccess or onFailure depending on its outcome, then acknowledges it to daraja.
+//Malformed results get a 400 status and hook errors a 500, either hook may be nil.
+func serveResult[T resultCoder](w http.ResponseWriter, r *http.Request, maxBytes int64, parse func(result *Result) (T, error), onSuccess, onFailure func(ctx context.Context, res T) error) {
+	result, ok := readResult(w, r, maxBytes)
+	if !ok {
+		return
+	}
+	res, err := parse(result)
+	if err != nil {
+		rejectCallback(w, http.StatusBadRequest, "Malformed result: "+err.Error())
+		return
+	}
+	hook := onFailure
+	if res.Code().Success() {
+		hook = onSuccess
+	}
+	if hook != nil {
+		err = hook(r.Context(), res)
+		if err != nil {
+			rejectCallback(w, http.StatusInternalServerError, "Result not processed")
+			return
+		}
+	}
+	writeCallbackAck(w, http.StatusOK, AcceptedAck)
+}
+
+//ResultFunc handles a parsed result callback, returning an error makes
+//the handler respond with a 500 status
+type ResultFunc func(ctx context.Context, result *Result) error
+
+//QueueTimeoutHandler is an http.Handler for the QueueTimeOutURL of b2c, b2b,
+//reversal, balance and transaction status requests. Daraja posts to it when a
+//request expired in its queue, its outcome is then unknown: check b2c, b2b and
+//reversal requests with TransactionStatus before any retry or they may be paid twice.
+//The body is parsed as a result when possible and passed to OnTimeout without validation.
+type QueueTimeoutHandler struct {
+	OnTimeout ResultFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewQueueTimeoutHandler returns *QueueTimeoutHandler
+func NewQueueTimeoutHandler(onTimeout ResultFunc) *QueueTimeoutHandler {
+	return &QueueTimeoutHandler{OnTimeout: onTimeout}
+}
+
+//ServeHTTP parses the timeout notification, dispatches it and acknowledges it to daraja
+func (h *QueueTimeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := readCallback(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+	result, err := parseResult(bytes.NewReader(body))
+	if err != nil {
+		result = &Result{}
+	}
+	if h.OnTimeout != nil {
+		err = h.OnTimeout(r.Context(), result)
+		if err != nil {
+			rejectCallback(w, http.StatusInternalServerError, "Timeout not processed")
+			return
+		}
+	}
+	writeCallbackAck(w, http.StatusOK, AcceptedAck)
+}
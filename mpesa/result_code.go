@@ -0,0 +1,138 @@
+package mpesa
+
+import (
+	"strconv"
+	"strings"
+)
+
+//ResultCode is the outcome of a transaction as reported by callbacks and queries
+type ResultCode int
+
+//result codes
+
+//ResultCodeUnknown the result code is missing or not numeric
+const ResultCodeUnknown ResultCode = -1
+
+//ResultCodeSuccess the transaction was successful
+const ResultCodeSuccess ResultCode = 0
+
+//ResultCodeInsufficientBalance the balance is insufficient for the transaction
+const ResultCodeInsufficientBalance ResultCode = 1
+
+//ResultCodeRuleLimited the transaction was limited by an M-Pesa rule e.g. too many attempts
+const ResultCodeRuleLimited ResultCode = 17
+
+//ResultCodeSystemBusy the system is busy or traffic is blocked
+const ResultCodeSystemBusy ResultCode = 26
+
+//ResultCodeSubscriberLocked a transaction is already in process for the subscriber
+const ResultCodeSubscriberLocked ResultCode = 1001
+
+//ResultCodeTransactionExpired the transaction has expired
+const ResultCodeTransactionExpired ResultCode = 1019
+
+//ResultCodePushRequestFailed an error occurred while sending the push request
+const ResultCodePushRequestFailed ResultCode = 1025
+
+//ResultCodeCancelledByUser the customer cancelled the request
+const ResultCodeCancelledByUser ResultCode = 1032
+
+//ResultCodeTimeout the customer could not be reached or did not respond in time
+const ResultCodeTimeout ResultCode = 1037
+
+//ResultCodeInvalidInitiator the initiator information is invalid e.g. the customer entered a wrong PIN
+const ResultCodeInvalidInitiator ResultCode = 2001
+
+//ResultCodeProcessing the transaction is still being processed
+const ResultCodeProcessing ResultCode = 4999
+
+//ResultCodePushError an error occurred while sending the push request
+const ResultCodePushError ResultCode = 9999
+
+var resultCodeDescriptions = map[ResultCode]string{
+	ResultCodeUnknown:             "Unknown result",
+	ResultCodeSuccess:             "The service request is processed successfully",
+	ResultCodeInsufficientBalance: "The balance is insufficient for the transaction",
+	ResultCodeRuleLimited:         "Rule limited",
+	ResultCodeSystemBusy:          "System busy",
+	ResultCodeSubscriberLocked:    "Unable to lock subscriber, a transaction is already in process for the current subscriber",
+	ResultCodeTransactionExpired:  "Transaction has expired",
+	ResultCodePushRequestFailed:   "An error occurred while sending a push request",
+	ResultCodeCancelledByUser:     "Request cancelled by user",
+	ResultCodeTimeout:             "DS timeout user cannot be reached",
+	ResultCodeInvalidInitiator:    "The initiator information is invalid",
+	ResultCodeProcessing:          "The transaction is still under processing",
+	ResultCodePushError:           "An error occurred while sending a push request",
+}
+
+//ResultStatus is the classification of a ResultCode
+type ResultStatus int
+
+//result statuses
+
+//ResultFailed the transaction failed
+const ResultFailed ResultStatus = 0
+
+//ResultSuccess the transaction was successful
+const ResultSuccess ResultStatus = 1
+
+//ResultPending the outcome is not known yet
+const ResultPending ResultStatus = 2
+
+//ResultCancelled the customer cancelled the transaction
+const ResultCancelled ResultStatus = 3
+
+//String returns the status name
+func (s ResultStatus) String() string {
+	switch s {
+	case ResultSuccess:
+		return "success"
+	case ResultPending:
+		return "pending"
+	case ResultCancelled:
+		return "cancelled"
+	}
+	return "failed"
+}
+
+//ParseResultCode parses a result code sent as a string, it returns ResultCodeUnknown
+//if code is empty or not numeric
+func ParseResultCode(code string) ResultCode {
+	c, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil {
+		return ResultCodeUnknown
+	}
+	return ResultCode(c)
+}
+
+//String returns the numeric code
+func (c ResultCode) String() string {
+	return strconv.Itoa(int(c))
+}
+
+//Description returns a human readable description of the code
+func (c ResultCode) Description() string {
+	if description, ok := resultCodeDescriptions[c]; ok {
+		return description
+	}
+	return "Transaction failed with result code " + c.String()
+}
+
+//Status classifies the code, codes not in the catalogue are failures
+//and an unknown code is pending until the outcome is reported
+func (c ResultCode) Status() ResultStatus {
+	switch c {
+	case ResultCodeSuccess:
+		return ResultSuccess
+	case ResultCodeCancelledByUser:
+		return ResultCancelled
+	case ResultCodeProcessing, ResultCodeUnknown:
+		return ResultPending
+	}
+	return ResultFailed
+}
+
+//Success reports whether the transaction was successful
+func (c ResultCode) Success() bool {
+	return c == ResultCodeSuccess
+}
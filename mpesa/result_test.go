@@ -0,0 +1,91 @@
+package mpesa_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+//resultBody returns a successful result callback body with params as its
+//ResultParameter and references as its ReferenceItem, either may be a list or an object
+func resultBody(params, references string) string {
+	return `{"Result":{"ResultType":0,"ResultCode":0,"ResultDesc":"The service request is processed successfully.",
+	"OriginatorConversationID":"10571-7910404-1","ConversationID":"AG_20191219_00004e48cf7e3533f581","TransactionID":"NLJ41HAY6Q",
+	"ResultParameters":{"ResultParameter":` + params + `},"ReferenceData":{"ReferenceItem":` + references + `}}}`
+}
+
+func TestParseResultParameters(t *testing.T) {
+	s := &mpesa.Mpesa{}
+	tests := []struct {
+		name       string
+		params     string
+		references string
+		keys       []string
+	}{
+		{"list", `[{"Key":"TransactionReceipt","Value":"NLJ41HAY6Q"},{"Key":"TransactionAmount","Value":10}]`, `[]`, []string{"TransactionReceipt", "TransactionAmount"}},
+		{"single object", `{"Key":"TransactionReceipt","Value":"NLJ41HAY6Q"}`, `{"Key":"QueueTimeoutURL","Value":"https://example.com/timeout"}`, []string{"TransactionReceipt"}},
+		{"empty list", `[]`, `[]`, nil},
+		{"null", `null`, `null`, nil},
+	}
+	for _, test := range tests {
+		result, err := s.ParseResult(strings.NewReader(resultBody(test.params, test.references)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(result.Parameters) != len(test.keys) {
+			t.Fatalf("%s: expected %d parameters, got %+v", test.name, len(test.keys), result.Parameters)
+		}
+		for i, key := range test.keys {
+			if result.Parameters[i].Key != key {
+				t.Fatalf("%s: expected parameter %s, got %s", test.name, key, result.Parameters[i].Key)
+			}
+		}
+		if !result.Code().Success() || result.ConversationID != "AG_20191219_00004e48cf7e3533f581" {
+			t.Fatalf("%s: unexpected result %+v", test.name, result)
+		}
+	}
+
+	result, err := s.ParseResult(strings.NewReader(resultBody(`{"Key":"TransactionAmount","Value":346237.55}`, `[]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := result.Param("TransactionAmount"); value != json.Number("346237.55") {
+		t.Fatalf("expected the amount to be kept as sent, got %v", value)
+	}
+
+	for _, params := range []string{`"NLJ41HAY6Q"`, `{"Key":`, `[{"Key":1}]`, `10`} {
+		if _, err := s.ParseResult(strings.NewReader(resultBody(params, `[]`))); err == nil {
+			t.Fatalf("%s: expected an error", params)
+		}
+	}
+}
+
+func TestParseB2CResult(t *testing.T) {
+	s := &mpesa.Mpesa{}
+	params := func(completed string) string {
+		return `[{"Key":"TransactionAmount","Value":10.5},{"Key":"TransactionReceipt","Value":"NLJ41HAY6Q"},
+		{"Key":"B2CRecipientIsRegisteredCustomer","Value":"Y"},{"Key":"TransactionCompletedDateTime","Value":"` + completed + `"}]`
+	}
+	res, err := s.ParseB2CResult(strings.NewReader(resultBody(params("19.12.2019 11:45:50"), `{"Key":"Occassion","Value":"salary"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TransactionCompletedDateTime.Equal(time.Date(2019, 12, 19, 8, 45, 50, 0, time.UTC)) {
+		t.Fatalf("expected the completion time in nairobi, got %v", res.TransactionCompletedDateTime)
+	}
+	if res.TransactionAmount != mpesa.Cents(1050) || res.TransactionReceipt != "NLJ41HAY6Q" || !res.B2CRecipientIsRegisteredCustomer {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res.Occasion != "salary" {
+		t.Fatalf("expected the misspelled Occassion to be read, got %q", res.Occasion)
+	}
+
+	for _, completed := range []string{"2019-12-19 11:45:50", "20191219114550", "19.12.2019"} {
+		if _, err = s.ParseB2CResult(strings.NewReader(resultBody(params(completed), `[]`))); err == nil {
+			t.Fatalf("%s: expected an error", completed)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+//DefaultRetryStatusCodes are the http status codes retried when RetryPolicy.RetryStatusCodes is not set,
+//APIError.Temporary also considers most unclassified errors with these status codes temporary
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+//RetryPolicy configures retries of transient failures, set Config.RetryPolicy to opt in.
+//
+//Queries (ExpressTransactionStatus, TransactionStatus & BalanceQuery) are retried on any
+//transient failure. Other requests may move money so they are only retried when the
+//failure happened before the request was sent, e.g. the oauth call or dialing daraja failed,
+//or when daraja rejected the access token.
+type RetryPolicy struct {
+	//MaxAttempts is the number of attempts including the first, values < 2 disable retries
+	MaxAttempts int
+	//BaseDelay optional defaults to 500ms, the delay doubles on every attempt
+	BaseDelay time.Duration
+	//MaxDelay optional defaults to 10s
+	MaxDelay time.Duration
+	//RetryStatusCodes optional defaults to DefaultRetryStatusCodes, errors with these
+	//status codes are only retried when APIError.Temporary is true
+	RetryStatusCodes []int
+	//RetryErrorCodes optional, APIError.ErrorCode values retried whatever their status code
+	RetryErrorCodes []string
+}
+
+//retryable reports whether the policy considers apiErr transient, permanent
+//errors fail fast whatever their status code
+func (p *RetryPolicy) retryable(apiErr *APIError) bool {
+	for _, code := range p.RetryErrorCodes {
+		if apiErr.ErrorCode == code {
+			return true
+		}
+	}
+	if !apiErr.Temporary() {
+		return false
+	}
+	statusCodes := p.RetryStatusCodes
+	if statusCodes == nil {
+		statusCodes = DefaultRetryStatusCodes
+	}
+	for _, statusCode := range statusCodes {
+		if apiErr.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+//shouldRetry decides whether a failed attempt is retried, sent reports whether
+//the api request may have reached daraja
+func (p *RetryPolicy) shouldRetry(attempt int, err error, sent, idempotent bool) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		//transport failure
+		return idempotent || !sent
+	}
+	if sent && apiErr.StatusCode == http.StatusUnauthorized {
+		//rejected before processing, the cached token has been invalidated
+		return true
+	}
+	return p.retryable(apiErr) && (idempotent || !sent)
+}
+
+//backoff returns the delay before the next attempt, exponential with jitter
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = 500 * time.Millisecond
+	}
+	max := p.MaxDelay
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	//wait between half and the full delay so concurrent clients spread out
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+//apiRequest sends api post request, retrying failures as allowed by Config.RetryPolicy
+func (s *Mpesa) apiRequest(ctx context.Context, endpoint string, payload interface{}, idempotent bool) (resp []byte, err error) {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	url, err := s.GetBaseURL()
+	if err != nil {
+		return
+	}
+	url += endpoint
+	policy := s.Config.RetryPolicy
+	for attempt := 1; ; attempt++ {
+		var sent bool
+		resp, sent, err = s.attempt(ctx, url, jsonPayload)
+		if !sent {
+			err = notSent(err)
+		}
+		if err == nil || !policy.shouldRetry(attempt, err, sent, idempotent) {
+			return
+		}
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+//notSentError wraps the failure of a request that never reached daraja, it matches ErrNotSent
+type notSentError struct {
+	err error
+}
+
+func (e *notSentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notSentError) Unwrap() error {
+	return e.err
+}
+
+func (e *notSentError) Is(target error) bool {
+	return target == ErrNotSent
+}
+
+//notSent marks err as the failure of a request that never reached daraja
+func notSent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &notSentError{err: err}
+}
+
+//attempt sends a single api request, sent reports whether the request was
+//written to the connection and so may have been processed by daraja
+func (s *Mpesa) attempt(ctx context.Context, url string, jsonPayload []byte) (resp []byte, sent bool, err error) {
+	//fetch the token first so the trace below only observes the api request
+	token, err := s.AccessTokenCtx(ctx)
+	if err != nil {
+		return
+	}
+	var wrote int32
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			atomic.StoreInt32(&wrote, 1)
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodPost, url, bytes.NewReader(jsonPayload))
+	if err != nil {
+		return
+	}
+	req.Header.Add("Content-Type", "application/json")
+	res, err := s.do(req, token)
+	sent = res != nil || atomic.LoadInt32(&wrote) == 1
+	if err != nil {
+		return
+	}
+	resp, err = ioutil.ReadAll(res.Body)
+	defer res.Body.Close()
+	if err != nil {
+		return
+	}
+	if res.StatusCode != 200 {
+		err = s.GetAPIError(res.Status, res.StatusCode, resp)
+	}
+	return
+}
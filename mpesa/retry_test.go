@@ -0,0 +1,204 @@
+package mpesa_test
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+//newRetryingMpesa returns a client retrying up to 3 attempts without waiting long
+func newRetryingMpesa(t *testing.T, srv *mpesatest.Server, transport http.RoundTripper) *mpesa.Mpesa {
+	t.Helper()
+	config := srv.Config()
+	config.Transport = transport
+	config.RetryPolicy = &mpesa.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	}
+	s, err := mpesa.NewMpesa(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+//dropFirstResponse sends the first request on path then loses its response,
+//as if the connection was reset after daraja received the request
+func dropFirstResponse(path string) http.RoundTripper {
+	var dropped int32
+	return mpesa.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil && req.URL.Path == path && atomic.CompareAndSwapInt32(&dropped, 0, 1) {
+			res.Body.Close()
+			return nil, errors.New("connection reset by peer")
+		}
+		return res, err
+	})
+}
+
+func b2cPayment() *mpesa.B2C {
+	return &mpesa.B2C{
+		InitiatorUserName: "testapi",
+		InitiatorPassword: "Safaricom007@",
+		ShortCode:         "600000",
+		PhoneNumber:       "0712345678",
+		Amount:            mpesa.KES(100),
+		ResultCallBackURL: "http://127.0.0.1:1/result",
+	}
+}
+
+func reversal() *mpesa.Reversal {
+	return &mpesa.Reversal{
+		TransactionID:     "LKXXXX1234",
+		InitiatorUserName: "testapi",
+		InitiatorPassword: "Safaricom007@",
+		ShortCode:         "600000",
+		Amount:            mpesa.KES(100),
+		ResultCallBackURL: "http://127.0.0.1:1/result",
+	}
+}
+
+var systemBusy = &mpesa.APIError{
+	StatusCode:   http.StatusServiceUnavailable,
+	ErrorCode:    "503.001.01",
+	ErrorMessage: "The service is temporarily unavailable",
+}
+
+func TestMoneyMovingRequestsNotRetriedOnceSent(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, nil)
+
+	srv.RespondWithAPIError(mpesatest.B2CPath, systemBusy)
+	_, err := s.B2C(b2cPayment())
+	if !errors.Is(err, mpesa.ErrSystemBusy) {
+		t.Fatalf("expected ErrSystemBusy, got %v", err)
+	}
+	srv.RespondWithAPIError(mpesatest.ReversalPath, systemBusy)
+	_, err = s.Reverse(reversal())
+	if !errors.Is(err, mpesa.ErrSystemBusy) {
+		t.Fatalf("expected ErrSystemBusy, got %v", err)
+	}
+	for _, path := range []string{mpesatest.B2CPath, mpesatest.ReversalPath} {
+		if n := len(srv.Requests(path)); n != 1 {
+			t.Fatalf("expected a single request on %s, got %d", path, n)
+		}
+	}
+}
+
+func TestMoneyMovingRequestsNotRetriedAfterLostResponse(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, dropFirstResponse(mpesatest.B2CPath))
+
+	_, err := s.B2C(b2cPayment())
+	if err == nil {
+		t.Fatal("expected the lost response to be reported")
+	}
+	if n := len(srv.Requests(mpesatest.B2CPath)); n != 1 {
+		t.Fatalf("expected a single b2c request, got %d", n)
+	}
+}
+
+func TestQueriesRetried(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, dropFirstResponse(mpesatest.BalancePath))
+
+	//the first error is lost with the first response
+	srv.RespondWithAPIError(mpesatest.BalancePath, systemBusy)
+	srv.RespondWithAPIError(mpesatest.BalancePath, systemBusy)
+	_, err := s.BalanceQuery(balanceQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	//one lost response, one api error and the successful attempt
+	if n := len(srv.Requests(mpesatest.BalancePath)); n != 3 {
+		t.Fatalf("expected 3 balance requests, got %d", n)
+	}
+}
+
+func TestMoneyMovingRequestsRetriedBeforeSending(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, nil)
+
+	srv.RespondWithAPIError(mpesatest.OAuthPath, systemBusy)
+	_, err := s.B2C(b2cPayment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(mpesatest.B2CPath)); n != 1 {
+		t.Fatalf("expected a single b2c request, got %d", n)
+	}
+	if n := len(srv.Requests(mpesatest.OAuthPath)); n != 2 {
+		t.Fatalf("expected the oauth call to be retried, got %d calls", n)
+	}
+}
+
+func TestMoneyMovingRequestsRetriedAfterUnauthorized(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, nil)
+
+	srv.RespondWithAPIError(mpesatest.B2CPath, &mpesa.APIError{
+		StatusCode:   http.StatusUnauthorized,
+		ErrorCode:    "404.001.03",
+		ErrorMessage: "Invalid Access Token",
+	})
+	_, err := s.B2C(b2cPayment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(mpesatest.B2CPath)); n != 2 {
+		t.Fatalf("expected the rejected b2c request to be sent again, got %d", n)
+	}
+	if n := len(srv.Requests(mpesatest.OAuthPath)); n != 2 {
+		t.Fatalf("expected a new access token, got %d oauth calls", n)
+	}
+}
+
+func TestDefaultRetryStatusCodes(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, nil)
+
+	//unclassified but its status code is one of DefaultRetryStatusCodes
+	srv.RespondWithAPIError(mpesatest.BalancePath, &mpesa.APIError{
+		StatusCode:   http.StatusBadGateway,
+		ErrorCode:    "502.001.01",
+		ErrorMessage: "Bad Gateway",
+	})
+	_, err := s.BalanceQuery(balanceQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(mpesatest.BalancePath)); n != 2 {
+		t.Fatalf("expected the query to be retried, got %d requests", n)
+	}
+}
+
+func TestPermanentServerErrorsNotRetried(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newRetryingMpesa(t, srv, nil)
+
+	srv.RespondWithAPIError(mpesatest.BalancePath, &mpesa.APIError{
+		StatusCode:   http.StatusInternalServerError,
+		ErrorCode:    "500.001.1001",
+		ErrorMessage: "The initiator information is invalid",
+	})
+	_, err := s.BalanceQuery(balanceQuery())
+	if err == nil {
+		t.Fatal("expected the permanent error to be returned")
+	}
+	if n := len(srv.Requests(mpesatest.BalancePath)); n != 1 {
+		t.Fatalf("expected a single balance request, got %d", n)
+	}
+}
@@ -1,14 +1,17 @@
 package mpesa
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"io"
 	"regexp"
 )
 
 //ReversalAPI service interface
 type ReversalAPI interface {
 	Reverse(r *Reversal) (apiRes *APIRes, err error)
+	ReverseCtx(ctx context.Context, r *Reversal) (apiRes *APIRes, err error)
+	ParseReversalRes(reversalRes io.Reader) (res *ReversalRes, err error)
 }
 
 //Reversal model
@@ -20,7 +23,7 @@ type Reversal struct {
 	ShortCode     string
 	PhoneNumber   string
 	receiverParty string
-	Amount        float32
+	Amount        Amount
 	//optional defaults to msdin for phone number and organization for shortcode
 	RecieverIdentifierType string
 	TimeOutCallBackURL     string
@@ -59,7 +62,7 @@ func (m *Reversal) OK() (err error) {
 		}
 	}
 	//amount
-	if m.Amount <= float32(0) {
+	if m.Amount.Sign() <= 0 {
 		err = fmt.Errorf("Must provide amount transacted, amount > 0")
 		return
 	}
@@ -119,7 +122,7 @@ type ReversalPayload struct {
 	RecieverIdentifierType string `json:"RecieverIdentifierType"`
 	// Remarks	Comments that are sent along with the transaction.
 	//Amount The amount transacted in that transaction to be reversed, down to the cent.
-	Amount  float32
+	Amount  Amount `json:"Amount"`
 	Remarks string `json:"Remarks"`
 	// QueueTimeOutURL	The path that stores information of time out transaction.
 	QueueTimeOutURL string `json:"QueueTimeOutURL"`
@@ -133,8 +136,14 @@ type ReversalPayload struct {
 
 //Reverse sends request to reverse a transaction
 func (s *Mpesa) Reverse(r *Reversal) (apiRes *APIRes, err error) {
+	return s.ReverseCtx(context.Background(), r)
+}
+
+//ReverseCtx is Reverse bound to ctx
+func (s *Mpesa) ReverseCtx(ctx context.Context, r *Reversal) (apiRes *APIRes, err error) {
 	err = r.OK()
 	if err != nil {
+		err = notSent(err)
 		return
 	}
 	//encrypt password
@@ -142,13 +151,12 @@ func (s *Mpesa) Reverse(r *Reversal) (apiRes *APIRes, err error) {
 	if(r.EncryptPassword){
 		securityCredential, err = EncryptPassword(r.InitiatorPassword, s.Config.Environment)
 		if err != nil {
+			err = notSent(err)
 			return
 		}
 	}else{
 		securityCredential = r.InitiatorPassword
 	}
-	amount := float32(math.Round(float64(r.Amount)*100) / 100)
-
 	payload := &ReversalPayload{
 		Initiator:              r.InitiatorUserName,
 		SecurityCredential:     securityCredential,
@@ -159,10 +167,10 @@ func (s *Mpesa) Reverse(r *Reversal) (apiRes *APIRes, err error) {
 		QueueTimeOutURL:        r.TimeOutCallBackURL,
 		ResultURL:              r.ResultCallBackURL,
 		TransactionID:          r.TransactionID,
-		Amount:                 amount,
+		Amount:                 r.Amount,
 	}
 	endpoint := "/mpesa/reversal/v1/request"
-	apiRes, err = s.APIRes(endpoint, payload)
+	apiRes, err = s.APIResCtx(ctx, endpoint, payload)
 	return
 
 }
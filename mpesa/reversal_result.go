@@ -0,0 +1,106 @@
+package mpesa
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+//reversal result codes, unlike other apis they are strings
+
+//ReversalAlreadyReversed result code, the original transaction was already reversed
+const ReversalAlreadyReversed string = "R000001"
+
+//ReversalInvalidTransactionID result code, the original transaction id is invalid
+const ReversalInvalidTransactionID string = "R000002"
+
+//ReversalRes is the typed result of a reversal, the fields other than
+//Result are only set for successful reversals
+type ReversalRes struct {
+	Result
+	//OriginalTransactionID is the reversed transaction
+	OriginalTransactionID string
+	Amount                Amount
+	Charge                Amount
+	//CreditPartyPublicName e.g. "254722000000 - John Doe" and DebitPartyPublicName
+	//e.g. "600000 - Safaricom" are the parties of the reversal
+	CreditPartyPublicName string
+	DebitPartyPublicName  string
+	//DebitAccountBalance is the balance of the debited account after the reversal
+	DebitAccountBalance []BalanceAccount
+	//TransCompletedTime in the Africa/Nairobi timezone
+	TransCompletedTime time.Time
+}
+
+//AlreadyReversed is true when the reversal failed because the transaction was already reversed
+func (r *ReversalRes) AlreadyReversed() bool {
+	return r.RawResultCode == ReversalAlreadyReversed
+}
+
+//newReversalRes converts the parameters of result
+func newReversalRes(result *Result) (res *ReversalRes, err error) {
+	completed, err := result.paramTime("TransCompletedTime", darajaTimeLayout)
+	if err != nil {
+		return
+	}
+	balance, err := parseAccountBalance(result.paramString("DebitAccountBalance"))
+	if err != nil {
+		return
+	}
+	amount, err := result.paramAmount("Amount")
+	if err != nil {
+		return
+	}
+	charge, err := result.paramAmount("Charge")
+	if err != nil {
+		return
+	}
+	res = &ReversalRes{
+		Result:                *result,
+		OriginalTransactionID: result.paramString("OriginalTransactionID"),
+		Amount:                amount,
+		Charge:                charge,
+		CreditPartyPublicName: result.paramString("CreditPartyPublicName"),
+		DebitPartyPublicName:  result.paramString("DebitPartyPublicName"),
+		DebitAccountBalance:   balance,
+		TransCompletedTime:    completed,
+	}
+	return
+}
+
+//ParseReversalRes parses the payload posted to the ResultCallBackURL of a reversal
+func (s *Mpesa) ParseReversalRes(reversalRes io.Reader) (res *ReversalRes, err error) {
+	result, err := parseResult(reversalRes)
+	if err != nil {
+		return
+	}
+	return newReversalRes(result)
+}
+
+//ReversalResFunc handles a parsed reversal result, returning an error makes
+//the handler respond with a 500 status
+type ReversalResFunc func(ctx context.Context, res *ReversalRes) error
+
+//ReversalResultHandler is an http.Handler for the reversal ResultCallBackURL,
+//successful reversals are passed to OnSuccess and all other outcomes to OnFailure.
+//Use a QueueTimeoutHandler for the TimeOutCallBackURL.
+type ReversalResultHandler struct {
+	OnSuccess ReversalResFunc
+	OnFailure ReversalResFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewReversalResultHandler returns *ReversalResultHandler, either hook may be nil
+func NewReversalResultHandler(onSuccess, onFailure ReversalResFunc) *ReversalResultHandler {
+	return &ReversalResultHandler{
+		OnSuccess: onSuccess,
+		OnFailure: onFailure,
+	}
+}
+
+//ServeHTTP parses the result, dispatches it and acknowledges it to daraja
+func (h *ReversalResultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, r, h.MaxBodyBytes, newReversalRes, h.OnSuccess, h.OnFailure)
+}
@@ -0,0 +1,204 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//ReversalState is the state of the reversal of a transaction
+type ReversalState int
+
+const (
+	//ReversalPending the reversal was accepted (or its outcome is unknown) and its result has not arrived yet
+	ReversalPending ReversalState = iota
+	//ReversalReturned the reversal completed, the money is back with the debited party
+	ReversalReturned
+	//ReversalAlreadyDone the transaction had already been reversed before
+	ReversalAlreadyDone
+	//ReversalFailed the reversal was rejected or failed e.g. invalid transaction id or amount, nothing was returned by it
+	ReversalFailed
+)
+
+//String returns the name of the state
+func (s ReversalState) String() string {
+	switch s {
+	case ReversalPending:
+		return "pending"
+	case ReversalReturned:
+		return "returned"
+	case ReversalAlreadyDone:
+		return "already reversed"
+	case ReversalFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+//Returned is true when the money of the transaction is back, by this reversal or a previous one
+func (s ReversalState) Returned() bool {
+	return s == ReversalReturned || s == ReversalAlreadyDone
+}
+
+//ReversalRecord links a reversal to the transaction it reverses
+type ReversalRecord struct {
+	//TransactionID of the reversed transaction
+	TransactionID            string
+	ConversationID           string
+	OriginatorConversationID string
+	State                    ReversalState
+	//Result nil until the result arrives
+	Result *ReversalRes
+	//Err why the reversal was not accepted, or why its outcome is unknown
+	Err error
+}
+
+//ReversalRecordFunc handles a reversal whose result arrived, returning an error makes
+//the handler respond with a 500 status
+type ReversalRecordFunc func(ctx context.Context, record *ReversalRecord) error
+
+//ReversalTracker links reversal results, which only carry the original
+//TransactionID when successful, back to the transaction being reversed.
+//Records are kept in memory, persist them from OnResult.
+type ReversalTracker struct {
+	//Mpesa sends the reversals, e.g. *Mpesa or a mock in tests
+	Mpesa ReversalAPI
+	//OnResult optional receives every tracked reversal once its result arrives
+	OnResult ReversalRecordFunc
+
+	mu             sync.Mutex
+	byConversation map[string]*ReversalRecord
+	byTransaction  map[string]*ReversalRecord
+}
+
+//NewReversalTracker returns *ReversalTracker
+func NewReversalTracker(s ReversalAPI, onResult ReversalRecordFunc) *ReversalTracker {
+	return &ReversalTracker{
+		Mpesa:          s,
+		OnResult:       onResult,
+		byConversation: map[string]*ReversalRecord{},
+		byTransaction:  map[string]*ReversalRecord{},
+	}
+}
+
+//Reverse validates r, tracks the reversal of r.TransactionID then sends it. When sending
+//fails the record is returned with the error, it is failed when the reversal certainly was
+//not made, otherwise it stays pending as a successful result can still arrive.
+//A transaction whose money is already returned is not reversed again.
+func (t *ReversalTracker) Reverse(ctx context.Context, r *Reversal) (record *ReversalRecord, err error) {
+	err = r.OK()
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	if existing, ok := t.byTransaction[r.TransactionID]; ok && existing.State.Returned() {
+		c := *existing
+		t.mu.Unlock()
+		record = &c
+		err = fmt.Errorf("Transaction %s is already reversed", r.TransactionID)
+		return
+	}
+	tracked := &ReversalRecord{TransactionID: r.TransactionID}
+	t.byTransaction[r.TransactionID] = tracked
+	t.mu.Unlock()
+
+	apiRes, err := t.Mpesa.ReverseCtx(ctx, r)
+	failed := rejected(err)
+	if err == nil && apiRes.ResponseCode != "0" {
+		err = &APIError{ErrorCode: apiRes.ResponseCode, ErrorMessage: apiRes.ResponseDescription}
+		failed = true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	//a result may already have arrived when the response was lost
+	if tracked.Result == nil {
+		tracked.Err = err
+		if failed {
+			tracked.State = ReversalFailed
+		}
+	}
+	if err == nil {
+		tracked.ConversationID = apiRes.ConversationID
+		tracked.OriginatorConversationID = apiRes.OriginatorConversationID
+		for _, id := range []string{tracked.ConversationID, tracked.OriginatorConversationID} {
+			if id != "" {
+				t.byConversation[id] = tracked
+			}
+		}
+	}
+	c := *tracked
+	record = &c
+	return
+}
+
+//Track tracks a reversal of transactionID sent without the tracker
+func (t *ReversalTracker) Track(transactionID string, apiRes *APIRes) *ReversalRecord {
+	record := &ReversalRecord{
+		TransactionID:            transactionID,
+		ConversationID:           apiRes.ConversationID,
+		OriginatorConversationID: apiRes.OriginatorConversationID,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range []string{record.ConversationID, record.OriginatorConversationID} {
+		if id != "" {
+			t.byConversation[id] = record
+		}
+	}
+	//the latest reversal is kept unless the money is already returned
+	if existing, ok := t.byTransaction[transactionID]; !ok || !existing.State.Returned() {
+		t.byTransaction[transactionID] = record
+	}
+	c := *record
+	return &c
+}
+
+//Status returns the latest reversal of transactionID, or the one that returned its money
+func (t *ReversalTracker) Status(transactionID string) (record ReversalRecord, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.byTransaction[transactionID]
+	if ok {
+		record = *r
+	}
+	return
+}
+
+//Handler returns a ReversalResultHandler delivering every result to t
+func (t *ReversalTracker) Handler() *ReversalResultHandler {
+	return NewReversalResultHandler(t.Deliver, t.Deliver)
+}
+
+//Deliver records res on the reversal it belongs to and passes it to OnResult,
+//results of untracked reversals are ignored. A reversal whose response was lost
+//is only matched by a successful result, which carries its TransactionID.
+func (t *ReversalTracker) Deliver(ctx context.Context, res *ReversalRes) (err error) {
+	t.mu.Lock()
+	record, ok := t.byConversation[res.ConversationID]
+	if !ok {
+		record, ok = t.byConversation[res.OriginatorConversationID]
+	}
+	if !ok && res.OriginalTransactionID != "" {
+		record, ok = t.byTransaction[res.OriginalTransactionID]
+	}
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	record.Result = res
+	switch {
+	case res.Code().Success():
+		record.State = ReversalReturned
+		record.Err = nil
+	case res.AlreadyReversed():
+		record.State = ReversalAlreadyDone
+	default:
+		record.State = ReversalFailed
+	}
+	c := *record
+	t.mu.Unlock()
+	if t.OnResult != nil {
+		err = t.OnResult(ctx, &c)
+	}
+	return
+}
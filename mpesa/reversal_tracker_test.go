@@ -0,0 +1,148 @@
+package mpesa_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+func TestReversalTrackerKeepsLostReversalsPending(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Transport = dropFirstResponse(mpesatest.ReversalPath)
+	s, err := mpesa.NewMpesa(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := make(chan mpesa.ReversalRecord, 1)
+	tracker := mpesa.NewReversalTracker(s, func(ctx context.Context, record *mpesa.ReversalRecord) error {
+		delivered <- *record
+		return nil
+	})
+	results := httptest.NewServer(tracker.Handler())
+	defer results.Close()
+
+	r := reversal()
+	r.ResultCallBackURL = results.URL
+	record, err := tracker.Reverse(context.Background(), r)
+	if err == nil {
+		t.Fatal("expected the lost response to be reported")
+	}
+	if record == nil || record.State != mpesa.ReversalPending || record.Err == nil {
+		t.Fatalf("expected a pending record with its error, got %+v", record)
+	}
+	srv.WaitCallbacks()
+	select {
+	case record := <-delivered:
+		if record.State != mpesa.ReversalReturned || record.Err != nil {
+			t.Fatalf("expected the result to mark the reversal returned, got %+v", record)
+		}
+	default:
+		t.Fatal("reversal result not delivered")
+	}
+	status, ok := tracker.Status(r.TransactionID)
+	if !ok || !status.State.Returned() {
+		t.Fatalf("expected the reversal to be returned, got %+v", status)
+	}
+}
+
+func TestReversalTrackerFailsRejectedReversals(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	tracker := mpesa.NewReversalTracker(newTestMpesa(t, srv), nil)
+
+	srv.RespondWithAPIError(mpesatest.ReversalPath, &mpesa.APIError{
+		StatusCode:   http.StatusBadRequest,
+		ErrorCode:    "400.002.02",
+		ErrorMessage: "Bad Request - Invalid Amount",
+	})
+	record, err := tracker.Reverse(context.Background(), reversal())
+	if err == nil || record.State != mpesa.ReversalFailed {
+		t.Fatalf("expected a failed record, got %+v, %v", record, err)
+	}
+	srv.RespondWithAPIError(mpesatest.ReversalPath, systemBusy)
+	record, err = tracker.Reverse(context.Background(), reversal())
+	if err == nil || record.State != mpesa.ReversalPending {
+		t.Fatalf("expected a 503 to keep the record pending, got %+v, %v", record, err)
+	}
+}
+
+func TestReversalTrackerValidatesBeforeTracking(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	tracker := mpesa.NewReversalTracker(newTestMpesa(t, srv), nil)
+
+	invalid := reversal()
+	invalid.Amount = mpesa.KES(0)
+	record, err := tracker.Reverse(context.Background(), invalid)
+	if err == nil || record != nil {
+		t.Fatalf("expected a validation error without record, got %+v, %v", record, err)
+	}
+	invalid = reversal()
+	invalid.TransactionID = ""
+	if _, err = tracker.Reverse(context.Background(), invalid); err == nil {
+		t.Fatal("expected an error for a missing TransactionID")
+	}
+	if _, ok := tracker.Status(reversal().TransactionID); ok {
+		t.Fatal("expected invalid reversals not to be tracked")
+	}
+	if _, ok := tracker.Status(""); ok {
+		t.Fatal("expected an empty TransactionID not to be tracked")
+	}
+	if n := len(srv.Requests(mpesatest.ReversalPath)); n != 0 {
+		t.Fatalf("expected no reversal request, got %d", n)
+	}
+}
+
+func TestReversalTrackerFailsReversalsNotSent(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	tracker := mpesa.NewReversalTracker(newTestMpesa(t, srv), nil)
+
+	srv.RespondWithAPIError(mpesatest.OAuthPath, &mpesa.APIError{
+		StatusCode:   http.StatusBadRequest,
+		ErrorCode:    "400.008.01",
+		ErrorMessage: "Invalid Authentication passed",
+	})
+	record, err := tracker.Reverse(context.Background(), reversal())
+	if !errors.Is(err, mpesa.ErrNotSent) || record.State != mpesa.ReversalFailed {
+		t.Fatalf("expected a failed record, got %+v, %v", record, err)
+	}
+}
+
+func TestReversalTrackerKeepsReturnedRecords(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	tracker := mpesa.NewReversalTracker(newTestMpesa(t, srv), nil)
+
+	r := reversal()
+	record, err := tracker.Reverse(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tracker.Deliver(context.Background(), &mpesa.ReversalRes{Result: mpesa.Result{
+		RawResultCode:  "0",
+		ConversationID: record.ConversationID,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err = tracker.Reverse(context.Background(), r)
+	if err == nil || record.State != mpesa.ReversalReturned {
+		t.Fatalf("expected the returned record and an error, got %+v, %v", record, err)
+	}
+	tracker.Track(r.TransactionID, &mpesa.APIRes{ConversationID: "AG_20191219_00005797af5d7d75f652"})
+	status, _ := tracker.Status(r.TransactionID)
+	if status.State != mpesa.ReversalReturned {
+		t.Fatalf("expected the returned record to be kept, got %+v", status)
+	}
+	if n := len(srv.Requests(mpesatest.ReversalPath)); n != 1 {
+		t.Fatalf("expected a single reversal request, got %d", n)
+	}
+}
@@ -0,0 +1,69 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+//STKCallbackFunc handles a parsed stk push callback, returning an error makes
+//the handler respond with a 500 status
+type STKCallbackFunc func(ctx context.Context, res *ParsedSTKCallBackRes) error
+
+//STKCallbackHandler is an http.Handler for the stk push CallBackURL,
+//successful payments are passed to OnSuccess and all other outcomes to OnFailure
+type STKCallbackHandler struct {
+	OnSuccess STKCallbackFunc
+	OnFailure STKCallbackFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewSTKCallbackHandler returns *STKCallbackHandler, either hook may be nil
+func NewSTKCallbackHandler(onSuccess, onFailure STKCallbackFunc) *STKCallbackHandler {
+	return &STKCallbackHandler{
+		OnSuccess: onSuccess,
+		OnFailure: onFailure,
+	}
+}
+
+//ServeHTTP parses the callback, dispatches it and acknowledges it to daraja
+func (h *STKCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := readCallback(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+	res, err := parseSTKCallBack(bytes.NewReader(body))
+	if err == nil {
+		err = res.validate()
+	}
+	if err != nil {
+		rejectCallback(w, http.StatusBadRequest, "Malformed callback: "+err.Error())
+		return
+	}
+	hook := h.OnFailure
+	if res.Code().Success() {
+		hook = h.OnSuccess
+	}
+	if hook != nil {
+		err = hook(r.Context(), res)
+		if err != nil {
+			rejectCallback(w, http.StatusInternalServerError, "Callback not processed")
+			return
+		}
+	}
+	writeCallbackAck(w, http.StatusOK, AcceptedAck)
+}
+
+//validate checks the fields every stk callback carries
+func (p *ParsedSTKCallBackRes) validate() (err error) {
+	if p.CheckoutRequestID == "" || p.MerchantRequestID == "" {
+		err = fmt.Errorf("Missing CheckoutRequestID or MerchantRequestID")
+		return
+	}
+	if p.Code().Success() && p.Meta.MpesaReceiptNumber == "" {
+		err = fmt.Errorf("Missing MpesaReceiptNumber in successful callback")
+	}
+	return
+}
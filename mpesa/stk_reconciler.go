@@ -0,0 +1,365 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//DefaultReconcileAfter is how old a pending stk push must be before STKReconciler queries it
+const DefaultReconcileAfter = time.Minute
+
+//DefaultReconcileInterval is how often STKReconciler.Run looks for pending stk pushes
+const DefaultReconcileInterval = 30 * time.Second
+
+//emittedMemory is how long STKReconciler remembers an outcome accepted by Hook to drop its duplicates
+const emittedMemory = time.Hour
+
+//PendingSTK is an stk push whose outcome is not known yet
+type PendingSTK struct {
+	CheckoutRequestID string    `json:"CheckoutRequestID"`
+	MerchantRequestID string    `json:"MerchantRequestID"`
+	ShortCode         string    `json:"ShortCode"`
+	PushedAt          time.Time `json:"PushedAt"`
+}
+
+//STKStore persists the pending stk pushes of an STKReconciler
+type STKStore interface {
+	Save(ctx context.Context, pending *PendingSTK) error
+	Delete(ctx context.Context, checkoutRequestID string) error
+	List(ctx context.Context) ([]*PendingSTK, error)
+}
+
+//MemorySTKStore is an in process STKStore, pending pushes are lost on restart
+type MemorySTKStore struct {
+	mu      sync.Mutex
+	pending map[string]*PendingSTK
+}
+
+//NewMemorySTKStore returns *MemorySTKStore
+func NewMemorySTKStore() *MemorySTKStore {
+	return &MemorySTKStore{pending: map[string]*PendingSTK{}}
+}
+
+//Save stores pending
+func (m *MemorySTKStore) Save(ctx context.Context, pending *PendingSTK) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := *pending
+	m.pending[p.CheckoutRequestID] = &p
+	return
+}
+
+//Delete removes the pending push
+func (m *MemorySTKStore) Delete(ctx context.Context, checkoutRequestID string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, checkoutRequestID)
+	return
+}
+
+//List returns the pending pushes
+func (m *MemorySTKStore) List(ctx context.Context) (pending []*PendingSTK, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.pending {
+		c := *p
+		pending = append(pending, &c)
+	}
+	return
+}
+
+//FileSTKStore is an STKStore keeping pending pushes in a json file so they survive restarts
+type FileSTKStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+//NewFileSTKStore returns *FileSTKStore, the file is created on the first Save
+func NewFileSTKStore(path string) (f *FileSTKStore, err error) {
+	if path == "" {
+		err = fmt.Errorf("Must provide stk store file path")
+		return
+	}
+	f = &FileSTKStore{Path: path}
+	return
+}
+
+func (f *FileSTKStore) read() (pending map[string]*PendingSTK, err error) {
+	pending = map[string]*PendingSTK{}
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &pending)
+	return
+}
+
+func (f *FileSTKStore) write(pending map[string]*PendingSTK) (err error) {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), ".stk-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return
+}
+
+//Save stores pending
+func (f *FileSTKStore) Save(ctx context.Context, pending *PendingSTK) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return
+	}
+	all[pending.CheckoutRequestID] = pending
+	return f.write(all)
+}
+
+//Delete removes the pending push
+func (f *FileSTKStore) Delete(ctx context.Context, checkoutRequestID string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return
+	}
+	if _, ok := all[checkoutRequestID]; !ok {
+		return
+	}
+	delete(all, checkoutRequestID)
+	return f.write(all)
+}
+
+//List returns the pending pushes
+func (f *FileSTKStore) List(ctx context.Context) (pending []*PendingSTK, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return
+	}
+	for _, p := range all {
+		pending = append(pending, p)
+	}
+	return
+}
+
+//STKReconciler tracks outstanding stk pushes and resolves those whose callback
+//got lost by querying their status. A push stays tracked until Hook accepts its
+//outcome. Duplicates of an accepted outcome, e.g. a repeated callback or a callback
+//racing ReconcileOnce, are dropped for an hour, Hook should still be idempotent as
+//a restart or another process sharing the store can deliver an outcome again.
+type STKReconciler struct {
+	//Mpesa sends and queries the pushes, e.g. *Mpesa or a mock in tests
+	Mpesa ExpressAPI
+	Store STKStore
+	//Hook receives the outcome of every tracked push
+	Hook STKCallbackFunc
+	//After optional defaults to DefaultReconcileAfter
+	After time.Duration
+	//Interval optional defaults to DefaultReconcileInterval
+	Interval time.Duration
+	//OnError optional receives errors of background reconciliation in Run
+	OnError func(err error)
+
+	mu sync.Mutex
+	//passkeys maps short codes to the lnm passkey needed to query their pushes
+	passkeys map[string]string
+	//inFlight are the pushes whose outcome is being passed to Hook
+	inFlight map[string]bool
+	//emitted are the pushes whose outcome Hook accepted, by the time it did
+	emitted map[string]time.Time
+}
+
+//NewSTKReconciler returns *STKReconciler, store defaults to a MemorySTKStore when nil
+func NewSTKReconciler(s ExpressAPI, store STKStore, hook STKCallbackFunc) *STKReconciler {
+	if store == nil {
+		store = NewMemorySTKStore()
+	}
+	return &STKReconciler{
+		Mpesa:    s,
+		Store:    store,
+		Hook:     hook,
+		passkeys: map[string]string{},
+		inFlight: map[string]bool{},
+		emitted:  map[string]time.Time{},
+	}
+}
+
+//SetPasskey sets the lnm passkey of shortCode, it is needed after a restart
+//to query pushes tracked by a previous process
+func (r *STKReconciler) SetPasskey(shortCode, passkey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.passkeys[shortCode] = passkey
+}
+
+func (r *STKReconciler) passkey(shortCode string) (passkey string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	passkey, ok = r.passkeys[shortCode]
+	return
+}
+
+//Handler returns an STKCallbackHandler resolving tracked pushes from their callbacks
+func (r *STKReconciler) Handler() *STKCallbackHandler {
+	return NewSTKCallbackHandler(r.Resolve, r.Resolve)
+}
+
+//STKPush sends an stk push and tracks it until it is resolved
+func (r *STKReconciler) STKPush(ctx context.Context, express *Express) (stkPushRes *STKPushRes, err error) {
+	stkPushRes, err = r.Mpesa.STKPushCtx(ctx, express)
+	if err != nil || stkPushRes.ResponseCode != "0" {
+		return
+	}
+	r.SetPasskey(express.ShortCode, express.Password)
+	err = r.Store.Save(ctx, &PendingSTK{
+		CheckoutRequestID: stkPushRes.CheckoutRequestID,
+		MerchantRequestID: stkPushRes.MerchantRequestID,
+		ShortCode:         express.ShortCode,
+		PushedAt:          time.Now(),
+	})
+	return
+}
+
+//Resolve emits res through Hook and stops tracking its push once Hook accepted it,
+//use it as the hook of the stk callback handler. A push stays tracked when Hook
+//fails so ReconcileOnce can recover its outcome.
+func (r *STKReconciler) Resolve(ctx context.Context, res *ParsedSTKCallBackRes) (err error) {
+	id := res.CheckoutRequestID
+	emitted, ok := r.claim(id)
+	if !ok {
+		//the same outcome is being passed to Hook, the push stays tracked if that fails
+		return
+	}
+	defer func() {
+		r.release(id, emitted)
+	}()
+	if !emitted && r.Hook != nil {
+		err = r.Hook(ctx, res)
+		if err != nil {
+			return
+		}
+	}
+	emitted = true
+	return r.Store.Delete(ctx, id)
+}
+
+//claim marks the push id in flight, ok is false when it already is,
+//emitted reports whether Hook recently accepted its outcome
+func (r *STKReconciler) claim(id string) (emitted, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inFlight[id] {
+		return
+	}
+	for other, at := range r.emitted {
+		if time.Since(at) > emittedMemory {
+			delete(r.emitted, other)
+		}
+	}
+	_, emitted = r.emitted[id]
+	r.inFlight[id] = true
+	ok = true
+	return
+}
+
+//release ends the claim on the push id, remembering it when its outcome was emitted
+func (r *STKReconciler) release(id string, emitted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.inFlight, id)
+	if emitted {
+		r.emitted[id] = time.Now()
+	}
+}
+
+//ReconcileOnce queries every tracked push older than After and resolves those
+//with a final outcome, it returns the first error met after trying all of them
+func (r *STKReconciler) ReconcileOnce(ctx context.Context) (err error) {
+	after := r.After
+	if after <= 0 {
+		after = DefaultReconcileAfter
+	}
+	pending, err := r.Store.List(ctx)
+	if err != nil {
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].PushedAt.Before(pending[j].PushedAt)
+	})
+	for _, p := range pending {
+		if time.Since(p.PushedAt) < after {
+			break
+		}
+		errX := r.reconcile(ctx, p)
+		if errX != nil && err == nil {
+			err = errX
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return
+}
+
+func (r *STKReconciler) reconcile(ctx context.Context, p *PendingSTK) (err error) {
+	passkey, ok := r.passkey(p.ShortCode)
+	if !ok {
+		err = fmt.Errorf("No passkey set for short code %s", p.ShortCode)
+		return
+	}
+	res, stillPending, err := querySTK(ctx, r.Mpesa, p.ShortCode, passkey, p.CheckoutRequestID)
+	if err != nil || stillPending {
+		return
+	}
+	if res.MerchantRequestID == "" {
+		res.MerchantRequestID = p.MerchantRequestID
+	}
+	return r.Resolve(ctx, res)
+}
+
+//Run reconciles every Interval until ctx is done, it returns ctx.Err()
+func (r *STKReconciler) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := r.ReconcileOnce(ctx)
+			if err != nil && r.OnError != nil && ctx.Err() == nil {
+				r.OnError(err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
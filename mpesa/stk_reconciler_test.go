@@ -0,0 +1,65 @@
+package mpesa_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+func TestSTKReconcilerKeepsPushUntilHookSucceeds(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+	ctx := context.Background()
+
+	var calls int32
+	store := mpesa.NewMemorySTKStore()
+	reconciler := mpesa.NewSTKReconciler(s, store, func(ctx context.Context, res *mpesa.ParsedSTKCallBackRes) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("database down")
+		}
+		return nil
+	})
+	reconciler.After = 1
+	callbacks := httptest.NewServer(reconciler.Handler())
+	defer callbacks.Close()
+
+	push, err := reconciler.STKPush(ctx, &mpesa.Express{
+		ShortCode:   "174379",
+		Password:    "passkey",
+		Amount:      mpesa.KES(1),
+		PhoneNumber: "0712345678",
+		CallBackURL: callbacks.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.WaitCallbacks()
+	if callback := srv.Callbacks()[0]; callback.StatusCode != 500 {
+		t.Fatalf("expected the failed hook to be reported to daraja, got %d", callback.StatusCode)
+	}
+	pending, _ := store.List(ctx)
+	if len(pending) != 1 {
+		t.Fatalf("expected the push to stay tracked after the hook failed, got %d", len(pending))
+	}
+
+	err = reconciler.ReconcileOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, _ = store.List(ctx)
+	if len(pending) != 0 || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected the query to resolve the push, %d pending after %d hook calls", len(pending), atomic.LoadInt32(&calls))
+	}
+
+	//a late duplicate of the callback
+	err = reconciler.Resolve(ctx, &mpesa.ParsedSTKCallBackRes{CheckoutRequestID: push.CheckoutRequestID})
+	if err != nil || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected the duplicate to be dropped, got %d hook calls, %v", atomic.LoadInt32(&calls), err)
+	}
+}
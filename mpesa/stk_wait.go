@@ -0,0 +1,201 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//DefaultSTKPollInterval is how often STKPushAndWait queries the status of a pending push
+const DefaultSTKPollInterval = 5 * time.Second
+
+//DefaultSTKPollAfter is how long STKPushAndWait waits for the callback before it starts polling
+const DefaultSTKPollAfter = 15 * time.Second
+
+//DefaultSTKWaitTimeout is how long STKPushAndWait waits when ctx has no deadline,
+//daraja gives up on unanswered prompts well before this
+const DefaultSTKWaitTimeout = 3 * time.Minute
+
+//earlyCallbackTTL is how long the registry keeps callbacks nobody is waiting for yet
+const earlyCallbackTTL = 5 * time.Minute
+
+type earlyCallback struct {
+	res        *ParsedSTKCallBackRes
+	receivedAt time.Time
+}
+
+//STKRegistry hands stk push callbacks to the callers waiting on them in STKPushAndWait,
+//serve Handler() (or pass Deliver to your own STKCallbackHandler) on the CallBackURL
+type STKRegistry struct {
+	mu      sync.Mutex
+	waiters map[string]chan *ParsedSTKCallBackRes
+	early   map[string]earlyCallback
+}
+
+//NewSTKRegistry returns *STKRegistry
+func NewSTKRegistry() *STKRegistry {
+	return &STKRegistry{
+		waiters: map[string]chan *ParsedSTKCallBackRes{},
+		early:   map[string]earlyCallback{},
+	}
+}
+
+//Handler returns an STKCallbackHandler delivering every callback to r
+func (r *STKRegistry) Handler() *STKCallbackHandler {
+	return NewSTKCallbackHandler(r.Deliver, r.Deliver)
+}
+
+//Deliver passes res to the caller waiting on its CheckoutRequestID, callbacks that
+//arrive before the caller starts waiting are kept for a few minutes
+func (r *STKRegistry) Deliver(ctx context.Context, res *ParsedSTKCallBackRes) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ch, ok := r.waiters[res.CheckoutRequestID]; ok {
+		delete(r.waiters, res.CheckoutRequestID)
+		ch <- res
+		return
+	}
+	now := time.Now()
+	for id, e := range r.early {
+		if now.Sub(e.receivedAt) > earlyCallbackTTL {
+			delete(r.early, id)
+		}
+	}
+	r.early[res.CheckoutRequestID] = earlyCallback{res: res, receivedAt: now}
+	return
+}
+
+//wait returns a channel receiving the callback of checkoutRequestID, call cancel when done
+func (r *STKRegistry) wait(checkoutRequestID string) (ch chan *ParsedSTKCallBackRes, cancel func()) {
+	ch = make(chan *ParsedSTKCallBackRes, 1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.early[checkoutRequestID]; ok {
+		delete(r.early, checkoutRequestID)
+		ch <- e.res
+	} else {
+		r.waiters[checkoutRequestID] = ch
+	}
+	cancel = func() {
+		r.mu.Lock()
+		if r.waiters[checkoutRequestID] == ch {
+			delete(r.waiters, checkoutRequestID)
+		}
+		r.mu.Unlock()
+	}
+	return
+}
+
+//STKWaitOptions configures STKPushAndWait
+type STKWaitOptions struct {
+	//Registry optional receives the callbacks of the push, the push is only polled when nil
+	Registry *STKRegistry
+	//PollAfter optional defaults to DefaultSTKPollAfter, or 0 when Registry is nil
+	PollAfter time.Duration
+	//PollInterval optional defaults to DefaultSTKPollInterval
+	PollInterval time.Duration
+}
+
+//STKPushError is returned by STKPushAndWait when daraja did not accept the push
+type STKPushError struct {
+	Res *STKPushRes
+}
+
+//Error returns error message
+func (e *STKPushError) Error() string {
+	if e.Res.ResponseDescription != "" {
+		return e.Res.ResponseDescription
+	}
+	return "Stk push not accepted: " + e.Res.ResponseCode
+}
+
+//Code returns the typed ResponseCode of the push
+func (e *STKPushError) Code() ResultCode {
+	return ParseResultCode(e.Res.ResponseCode)
+}
+
+//STKPushAndWait sends an stk push and blocks until its final outcome is known,
+//either from the callback delivered to opts.Registry or by polling ExpressTransactionStatus.
+//Outcomes found by polling carry no Meta. A push daraja did not accept returns an *STKPushError.
+//Failed queries are retried at every poll, unless daraja rejected the query for good.
+//When ctx is done first it returns ctx.Err() joined with the last query error,
+//a ctx without deadline is limited to DefaultSTKWaitTimeout.
+func (s *Mpesa) STKPushAndWait(ctx context.Context, express *Express, opts *STKWaitOptions) (res *ParsedSTKCallBackRes, err error) {
+	if opts == nil {
+		opts = &STKWaitOptions{}
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultSTKWaitTimeout)
+		defer cancel()
+	}
+	stkPushRes, err := s.STKPushCtx(ctx, express)
+	if err != nil {
+		return
+	}
+	if stkPushRes.ResponseCode != "0" {
+		err = &STKPushError{Res: stkPushRes}
+		return
+	}
+	var callbacks chan *ParsedSTKCallBackRes
+	pollAfter := opts.PollAfter
+	if opts.Registry != nil {
+		var cancel func()
+		callbacks, cancel = opts.Registry.wait(stkPushRes.CheckoutRequestID)
+		defer cancel()
+		if pollAfter <= 0 {
+			pollAfter = DefaultSTKPollAfter
+		}
+	}
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultSTKPollInterval
+	}
+	timer := time.NewTimer(pollAfter)
+	defer timer.Stop()
+	var queryErr error
+	for {
+		select {
+		case res = <-callbacks:
+			return
+		case <-timer.C:
+			var pending bool
+			res, pending, err = querySTK(ctx, s, express.ShortCode, express.Password, stkPushRes.CheckoutRequestID)
+			if pending {
+				queryErr, err = err, nil
+			}
+			if !pending {
+				return
+			}
+			timer.Reset(pollInterval)
+		case <-ctx.Done():
+			err = errors.Join(ctx.Err(), queryErr)
+			return
+		}
+	}
+}
+
+//querySTK queries the outcome of an stk push, pending is true while the customer
+//has not responded or daraja is unable to tell, e.g. a temporary api error or a
+//network failure, err is then the failure of the query if any
+func querySTK(ctx context.Context, api ExpressAPI, shortCode, password, checkoutRequestID string) (res *ParsedSTKCallBackRes, pending bool, err error) {
+	ts, err := api.ExpressTransactionStatusCtx(ctx, shortCode, password, checkoutRequestID)
+	if err != nil {
+		var apiErr *APIError
+		pending = !errors.As(err, &apiErr) || apiErr.Temporary()
+		return
+	}
+	code := ts.Code()
+	if code.Status() == ResultPending {
+		pending = true
+		return
+	}
+	res = &ParsedSTKCallBackRes{
+		MerchantRequestID: ts.MerchantRequestID,
+		CheckoutRequestID: checkoutRequestID,
+		ResultCode:        int(code),
+		ResultDesc:        ts.ResultDesc,
+	}
+	return
+}
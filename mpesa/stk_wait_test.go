@@ -0,0 +1,81 @@
+package mpesa_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+	"github.com/jakhax/go_daraja/mpesa/mpesatest"
+)
+
+func newWaitingMpesa(t *testing.T, srv *mpesatest.Server, transport http.RoundTripper) *mpesa.Mpesa {
+	t.Helper()
+	config := srv.Config()
+	config.Transport = transport
+	s, err := mpesa.NewMpesa(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSTKPushAndWaitPollsThroughNetworkErrors(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newWaitingMpesa(t, srv, dropFirstResponse(mpesatest.STKQueryPath))
+
+	//the callback is lost, the outcome is only known by polling
+	srv.EnqueueResult(mpesatest.STKPushPath, &mpesatest.Result{Drop: true, Delay: 30 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := s.STKPushAndWait(ctx, expressPayment("", ""), &mpesa.STKWaitOptions{PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Code().Success() {
+		t.Fatalf("expected a successful push, got %+v", res)
+	}
+	if n := len(srv.Requests(mpesatest.STKQueryPath)); n < 2 {
+		t.Fatalf("expected polling to go on after the lost response, got %d queries", n)
+	}
+}
+
+func TestSTKPushAndWaitReturnsLastQueryError(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newWaitingMpesa(t, srv, mpesa.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == mpesatest.STKQueryPath {
+			return nil, errors.New("connection reset by peer")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	}))
+
+	srv.EnqueueResult(mpesatest.STKPushPath, &mpesatest.Result{Drop: true, Delay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := s.STKPushAndWait(ctx, expressPayment("", ""), &mpesa.STKWaitOptions{PollInterval: 10 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "connection reset by peer") {
+		t.Fatalf("expected the deadline joined with the query error, got %v", err)
+	}
+}
+
+func TestSTKPushAndWaitRejectedPush(t *testing.T) {
+	srv := mpesatest.NewServer()
+	defer srv.Close()
+	s := newTestMpesa(t, srv)
+
+	srv.RespondWithCode(mpesatest.STKPushPath, "1", "Insufficient balance")
+	_, err := s.STKPushAndWait(context.Background(), expressPayment("", ""), nil)
+	var pushErr *mpesa.STKPushError
+	if !errors.As(err, &pushErr) || pushErr.Code() != mpesa.ResultCodeInsufficientBalance || pushErr.Res.CheckoutRequestID == "" {
+		t.Fatalf("expected an *STKPushError, got %#v", err)
+	}
+	var apiErr *mpesa.APIError
+	if errors.As(err, &apiErr) {
+		t.Fatal("a push not accepted is not an api error")
+	}
+}
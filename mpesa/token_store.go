@@ -0,0 +1,304 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+//TokenStore holds daraja access tokens between requests.
+//Implement it on top of a shared store (e.g. redis) so several service instances
+//reuse a single access token instead of each calling the oauth endpoint.
+type TokenStore interface {
+	//Get returns the token stored under key, ok is false if there is none or it has expired
+	Get(ctx context.Context, key string) (token string, ok bool, err error)
+	//Set stores token under key, it must not be returned by Get after ttl
+	Set(ctx context.Context, key, token string, ttl time.Duration) error
+	//Delete removes the token stored under key
+	Delete(ctx context.Context, key string) error
+	//Lock acquires an exclusive lock for refreshing the token under key,
+	//it blocks until the lock is held or ctx is done, unlock releases it
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+type memoryToken struct {
+	token  string
+	expiry time.Time
+}
+
+//MemoryTokenStore is an in process TokenStore, it is the default store of the Mpesa service
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+	//locks holds a one slot semaphore per key so waiting can be cancelled
+	locks map[string]chan struct{}
+}
+
+//NewMemoryTokenStore returns *MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: map[string]memoryToken{},
+		locks:  map[string]chan struct{}{},
+	}
+}
+
+//Get returns the token stored under key
+func (m *MemoryTokenStore) Get(ctx context.Context, key string) (token string, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, found := m.tokens[key]
+	if !found || !time.Now().Before(t.expiry) {
+		return
+	}
+	token = t.token
+	ok = true
+	return
+}
+
+//Set stores token under key for ttl
+func (m *MemoryTokenStore) Set(ctx context.Context, key, token string, ttl time.Duration) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[key] = memoryToken{
+		token:  token,
+		expiry: time.Now().Add(ttl),
+	}
+	return
+}
+
+//Delete removes the token stored under key
+func (m *MemoryTokenStore) Delete(ctx context.Context, key string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, key)
+	return
+}
+
+//Lock acquires the refresh lock for key
+func (m *MemoryTokenStore) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = make(chan struct{}, 1)
+		m.locks[key] = l
+	}
+	m.mu.Unlock()
+	select {
+	case l <- struct{}{}:
+		unlock = func() {
+			<-l
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+//DefaultFileLockTimeout is how old a lock file must be before FileTokenStore treats it as abandoned
+const DefaultFileLockTimeout = 30 * time.Second
+
+//fileLockPollInterval is how often FileTokenStore retries a held lock
+const fileLockPollInterval = 50 * time.Millisecond
+
+//FileTokenStore is a TokenStore that keeps tokens as files in a directory,
+//it can be shared by processes on the same host or on a shared volume.
+type FileTokenStore struct {
+	Dir string
+	//LockTimeout optional defaults to DefaultFileLockTimeout
+	LockTimeout time.Duration
+}
+
+//NewFileTokenStore returns *FileTokenStore, creating dir if it does not exist
+func NewFileTokenStore(dir string) (f *FileTokenStore, err error) {
+	if dir == "" {
+		err = fmt.Errorf("Must provide token store directory")
+		return
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+	f = &FileTokenStore{Dir: dir}
+	return
+}
+
+type fileToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+func (f *FileTokenStore) path(key, ext string) string {
+	return filepath.Join(f.Dir, unsafeFileChars.ReplaceAllString(key, "_")+ext)
+}
+
+//Get returns the token stored under key
+func (f *FileTokenStore) Get(ctx context.Context, key string) (token string, ok bool, err error) {
+	data, err := ioutil.ReadFile(f.path(key, ".json"))
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	t := &fileToken{}
+	err = json.Unmarshal(data, t)
+	if err != nil {
+		return
+	}
+	if t.AccessToken == "" || !time.Now().Before(t.ExpiresAt) {
+		return
+	}
+	token = t.AccessToken
+	ok = true
+	return
+}
+
+//Set stores token under key for ttl, the file is replaced atomically
+func (f *FileTokenStore) Set(ctx context.Context, key, token string, ttl time.Duration) (err error) {
+	data, err := json.Marshal(&fileToken{
+		AccessToken: token,
+		ExpiresAt:   time.Now().Add(ttl),
+	})
+	if err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(f.Dir, ".token-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	err = os.Rename(tmp.Name(), f.path(key, ".json"))
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return
+}
+
+//Delete removes the token stored under key
+func (f *FileTokenStore) Delete(ctx context.Context, key string) (err error) {
+	err = os.Remove(f.path(key, ".json"))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+//Lock acquires the refresh lock for key by exclusively creating a lock file
+//holding a unique token, lock files older than LockTimeout are considered
+//abandoned and removed. Only a lock file still holding the token that was
+//checked is ever removed, so a lock is never released by another holder.
+func (f *FileTokenStore) Lock(ctx context.Context, key string) (unlock func(), err error) {
+	timeout := f.LockTimeout
+	if timeout <= 0 {
+		timeout = DefaultFileLockTimeout
+	}
+	lockPath := f.path(key, ".lock")
+	token, err := newLockToken()
+	if err != nil {
+		return
+	}
+	for {
+		file, errX := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if errX == nil {
+			_, errX = file.WriteString(token)
+			if closeErr := file.Close(); errX == nil {
+				errX = closeErr
+			}
+			if errX != nil {
+				removeLock(lockPath, token)
+				err = errX
+				return
+			}
+			unlock = func() {
+				removeLock(lockPath, token)
+			}
+			return
+		}
+		if !os.IsExist(errX) {
+			err = errX
+			return
+		}
+		info, errX := os.Stat(lockPath)
+		if errX == nil && time.Since(info.ModTime()) > timeout {
+			stale, errX := ioutil.ReadFile(lockPath)
+			if errX == nil {
+				err = removeLock(lockPath, string(stale))
+				if err != nil {
+					return
+				}
+			}
+			continue
+		}
+		select {
+		case <-time.After(fileLockPollInterval):
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
+
+//newLockToken returns a random token identifying a lock holder
+func newLockToken() (token string, err error) {
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+	token = hex.EncodeToString(b)
+	return
+}
+
+//removeLock removes the lock file at lockPath if it holds token. The file is
+//renamed aside before it is checked so a lock created concurrently by another
+//holder is put back instead of removed.
+func removeLock(lockPath, token string) (err error) {
+	aside, err := newLockToken()
+	if err != nil {
+		return
+	}
+	aside = lockPath + "." + aside
+	err = os.Rename(lockPath, aside)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(aside)
+	if err == nil && string(data) == token {
+		err = os.Remove(aside)
+		return
+	}
+	return restoreLock(aside, lockPath)
+}
+
+//restoreLock puts back a lock moved aside that was not the one checked, it fails
+//when a new holder took the lock meanwhile and then keeps the moved lock at aside
+func restoreLock(aside, lockPath string) (err error) {
+	err = os.Link(aside, lockPath)
+	if err != nil {
+		err = fmt.Errorf("Unable to restore lock %s moved aside to %s: %v", lockPath, aside, err)
+		return
+	}
+	return os.Remove(aside)
+}
@@ -0,0 +1,58 @@
+package mpesa
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveLockReportsLockMovedFromNewHolder(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "k.lock")
+	aside := lockPath + ".aside"
+	//the stale lock was replaced by a new holder after it was read, then moved aside
+	//while yet another holder created the lock
+	err := ioutil.WriteFile(aside, []byte("new holder"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(lockPath, []byte("newest holder"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreLock(aside, lockPath); err == nil {
+		t.Fatal("expected an error as the moved lock could not be restored")
+	}
+	data, err := ioutil.ReadFile(aside)
+	if err != nil || string(data) != "new holder" {
+		t.Fatalf("expected the moved lock to be kept aside, got %q, %v", data, err)
+	}
+	data, err = ioutil.ReadFile(lockPath)
+	if err != nil || string(data) != "newest holder" {
+		t.Fatalf("expected the newest holder's lock to be kept, got %q, %v", data, err)
+	}
+}
+
+func TestRemoveLockRestoresNewHoldersLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "k.lock")
+	//the stale lock read as "abandoned" was replaced by a new holder
+	err := ioutil.WriteFile(lockPath, []byte("new holder"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = removeLock(lockPath, "abandoned"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil || string(data) != "new holder" {
+		t.Fatalf("expected the new holder's lock to be restored, got %q, %v", data, err)
+	}
+	files, _ := filepath.Glob(lockPath + ".*")
+	if len(files) != 0 {
+		t.Fatalf("expected nothing left aside, got %v", files)
+	}
+	if err = removeLock(filepath.Join(dir, "missing.lock"), "abandoned"); err != nil {
+		t.Fatal(err)
+	}
+}
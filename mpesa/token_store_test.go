@@ -0,0 +1,89 @@
+package mpesa_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+func TestFileTokenStoreBreaksStaleLockOnce(t *testing.T) {
+	dir := t.TempDir()
+	store, err := mpesa.NewFileTokenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.LockTimeout = time.Minute
+	lockPath := filepath.Join(dir, "k.lock")
+	err = ioutil.WriteFile(lockPath, []byte("abandoned"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	err = os.Chtimes(lockPath, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var holders, maxHolders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := store.Lock(ctx, "k")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := atomic.AddInt32(&holders, 1)
+			for {
+				max := atomic.LoadInt32(&maxHolders)
+				if n <= max || atomic.CompareAndSwapInt32(&maxHolders, max, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if maxHolders != 1 {
+		t.Fatalf("expected a single lock holder at a time, got %d", maxHolders)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the lock file to be released, got %v", err)
+	}
+}
+
+func TestFileTokenStoreUnlockKeepsOtherHoldersLock(t *testing.T) {
+	dir := t.TempDir()
+	store, err := mpesa.NewFileTokenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := store.Lock(context.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the lock was broken and taken by another holder meanwhile
+	lockPath := filepath.Join(dir, "k.lock")
+	err = ioutil.WriteFile(lockPath, []byte("other holder"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil || string(data) != "other holder" {
+		t.Fatalf("expected the other holder's lock to be kept, got %q, %v", data, err)
+	}
+}
@@ -1,13 +1,17 @@
 package mpesa
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"regexp"
 )
 
 //TransactionStatusAPI service interface
 type TransactionStatusAPI interface {
 	TransactionStatus(ts *TransactionStatus) (apiRes *APIRes, err error)
+	TransactionStatusCtx(ctx context.Context, ts *TransactionStatus) (apiRes *APIRes, err error)
+	ParseTransactionStatusRes(transactionStatusRes io.Reader) (res *TransactionStatusRes, err error)
 }
 
 // TransactionStatus model
@@ -121,6 +125,11 @@ type TransactionStatusPayload struct {
 
 //TransactionStatus get a transaction's status
 func (s *Mpesa) TransactionStatus(ts *TransactionStatus) (apiRes *APIRes, err error) {
+	return s.TransactionStatusCtx(context.Background(), ts)
+}
+
+//TransactionStatusCtx is TransactionStatus bound to ctx
+func (s *Mpesa) TransactionStatusCtx(ctx context.Context, ts *TransactionStatus) (apiRes *APIRes, err error) {
 	err = ts.OK()
 	if err != nil {
 		return
@@ -143,6 +152,6 @@ func (s *Mpesa) TransactionStatus(ts *TransactionStatus) (apiRes *APIRes, err er
 		TransactionID:      ts.TransactionID,
 	}
 	endpoint := "/mpesa/transactionstatus/v1/query"
-	apiRes, err = s.APIRes(endpoint, payload)
+	apiRes, err = s.apiRes(ctx, endpoint, payload, true)
 	return
 }
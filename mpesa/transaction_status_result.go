@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+//TransactionStatusRes is the typed result of a transaction status query, the fields
+//other than Result describe the queried transaction and are only set for successful queries
+type TransactionStatusRes struct {
+	Result
+	ReceiptNo string
+	//TransactionConversationID & TransactionOriginatorConversationID are the ids
+	//of the queried transaction, not of the query
+	TransactionConversationID           string
+	TransactionOriginatorConversationID string
+	//TransactionStatus e.g. "Completed"
+	TransactionStatus string
+	Amount            Amount
+	//ReasonType e.g. "Business Payment to Customer via API"
+	ReasonType        string
+	TransactionReason string
+	DebitPartyCharges string
+	//DebitAccountType e.g. "Utility Account"
+	DebitAccountType string
+	//DebitPartyName & CreditPartyName e.g. "600000 - Safaricom", "254722000000 - John Doe"
+	DebitPartyName  string
+	CreditPartyName string
+	//InitiatedTime & FinalisedTime in the Africa/Nairobi timezone
+	InitiatedTime time.Time
+	FinalisedTime time.Time
+}
+
+//newTransactionStatusRes converts the parameters of result
+func newTransactionStatusRes(result *Result) (res *TransactionStatusRes, err error) {
+	initiated, err := result.paramTime("InitiatedTime", darajaTimeLayout)
+	if err != nil {
+		return
+	}
+	finalised, err := result.paramTime("FinalisedTime", darajaTimeLayout)
+	if err != nil {
+		return
+	}
+	amount, err := result.paramAmount("Amount")
+	if err != nil {
+		return
+	}
+	res = &TransactionStatusRes{
+		Result:                              *result,
+		ReceiptNo:                           result.paramString("ReceiptNo"),
+		TransactionConversationID:           result.paramString("ConversationID"),
+		TransactionOriginatorConversationID: result.paramString("OriginatorConversationID"),
+		TransactionStatus:                   result.paramString("TransactionStatus"),
+		Amount:                              amount,
+		ReasonType:                          result.paramString("ReasonType"),
+		TransactionReason:                   result.paramString("TransactionReason"),
+		DebitPartyCharges:                   result.paramString("DebitPartyCharges"),
+		DebitAccountType:                    result.paramString("DebitAccountType"),
+		DebitPartyName:                      result.paramString("DebitPartyName"),
+		CreditPartyName:                     result.paramString("CreditPartyName"),
+		InitiatedTime:                       initiated,
+		FinalisedTime:                       finalised,
+	}
+	return
+}
+
+//ParseTransactionStatusRes parses the payload posted to the ResultCallBackURL of a transaction status query
+func (s *Mpesa) ParseTransactionStatusRes(transactionStatusRes io.Reader) (res *TransactionStatusRes, err error) {
+	result, err := parseResult(transactionStatusRes)
+	if err != nil {
+		return
+	}
+	return newTransactionStatusRes(result)
+}
+
+//TransactionStatusResFunc handles a parsed transaction status result, returning an error makes
+//the handler respond with a 500 status
+type TransactionStatusResFunc func(ctx context.Context, res *TransactionStatusRes) error
+
+//TransactionStatusResultHandler is an http.Handler for the transaction status ResultCallBackURL,
+//successful queries are passed to OnSuccess and all other outcomes to OnFailure.
+//Use a QueueTimeoutHandler for the TimeOutCallBackURL.
+type TransactionStatusResultHandler struct {
+	OnSuccess TransactionStatusResFunc
+	OnFailure TransactionStatusResFunc
+	//MaxBodyBytes optional defaults to DefaultMaxCallbackBodyBytes
+	MaxBodyBytes int64
+}
+
+//NewTransactionStatusResultHandler returns *TransactionStatusResultHandler, either hook may be nil
+func NewTransactionStatusResultHandler(onSuccess, onFailure TransactionStatusResFunc) *TransactionStatusResultHandler {
+	return &TransactionStatusResultHandler{
+		OnSuccess: onSuccess,
+		OnFailure: onFailure,
+	}
+}
+
+//ServeHTTP parses the result, dispatches it and acknowledges it to daraja
+func (h *TransactionStatusResultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, r, h.MaxBodyBytes, newTransactionStatusRes, h.OnSuccess, h.OnFailure)
+}
@@ -0,0 +1,52 @@
+package mpesa_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakhax/go_daraja/mpesa"
+)
+
+//transactionStatusParams returns the result parameters of a transaction status query
+func transactionStatusParams(initiated, finalised string) string {
+	return `[{"Key":"ReceiptNo","Value":"NLJ41HAY6Q"},{"Key":"Amount","Value":100},{"Key":"TransactionStatus","Value":"Completed"},
+	{"Key":"InitiatedTime","Value":` + initiated + `},{"Key":"FinalisedTime","Value":` + finalised + `}]`
+}
+
+func TestParseTransactionStatusResTimes(t *testing.T) {
+	s := &mpesa.Mpesa{}
+	tests := []struct {
+		name      string
+		initiated string
+		finalised string
+		want      time.Time
+	}{
+		{"numbers", `20191219102115`, `20191219102115`, time.Date(2019, 12, 19, 7, 21, 15, 0, time.UTC)},
+		{"strings", `"20191219102115"`, `" 20191219102115 "`, time.Date(2019, 12, 19, 7, 21, 15, 0, time.UTC)},
+		{"missing", `""`, `null`, time.Time{}},
+	}
+	for _, test := range tests {
+		res, err := s.ParseTransactionStatusRes(strings.NewReader(resultBody(transactionStatusParams(test.initiated, test.finalised), `[]`)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !res.InitiatedTime.Equal(test.want) || !res.FinalisedTime.Equal(test.want) {
+			t.Fatalf("%s: expected %v, got %v and %v", test.name, test.want, res.InitiatedTime, res.FinalisedTime)
+		}
+		if res.ReceiptNo != "NLJ41HAY6Q" || res.Amount != mpesa.KES(100) || res.TransactionStatus != "Completed" {
+			t.Fatalf("%s: unexpected result %+v", test.name, res)
+		}
+	}
+
+	for _, value := range []string{`"19.12.2019 10:21:15"`, `"2019-12-19T10:21:15Z"`, `201912191021`, `20191319102115`} {
+		params := transactionStatusParams(value, `20191219102115`)
+		if _, err := s.ParseTransactionStatusRes(strings.NewReader(resultBody(params, `[]`))); err == nil {
+			t.Fatalf("InitiatedTime %s: expected an error", value)
+		}
+		params = transactionStatusParams(`20191219102115`, value)
+		if _, err := s.ParseTransactionStatusRes(strings.NewReader(resultBody(params, `[]`))); err == nil {
+			t.Fatalf("FinalisedTime %s: expected an error", value)
+		}
+	}
+}
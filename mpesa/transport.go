@@ -0,0 +1,48 @@
+package mpesa
+
+import (
+	"net/http"
+	"time"
+)
+
+//DefaultTimeout is the timeout of the http client used when Config.HTTPClient is not set
+const DefaultTimeout = 30 * time.Second
+
+//Middleware wraps the transport used for both oauth and api requests,
+//use it to add logging, metrics, tracing or to alter requests and responses
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+//RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+//RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//httpClient returns the client used for all daraja requests, it is built once from
+//Config.HTTPClient, Config.Transport and Config.Middleware
+func (s *Mpesa) httpClient() *http.Client {
+	s.clientOnce.Do(func() {
+		client := &http.Client{Timeout: DefaultTimeout}
+		if s.Config.HTTPClient != nil {
+			//copy so wrapping the transport does not affect the caller's client
+			c := *s.Config.HTTPClient
+			client = &c
+		}
+		transport := client.Transport
+		if s.Config.Transport != nil {
+			transport = s.Config.Transport
+		}
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		//the first middleware is the outermost, it sees requests first and responses last
+		for i := len(s.Config.Middleware) - 1; i >= 0; i-- {
+			transport = s.Config.Middleware[i](transport)
+		}
+		client.Transport = transport
+		s.client = client
+	})
+	return s.client
+}
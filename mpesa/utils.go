@@ -1,10 +1,53 @@
 package mpesa
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/nyaruka/phonenumbers"
+	"strconv"
+	"strings"
+	"time"
 )
 
+//darajaTimeLayout is the layout of timestamps sent by daraja e.g. 20191219102115
+const darajaTimeLayout = "20060102150405"
+
+//nairobi is the timezone of daraja timestamps
+var nairobi = loadNairobi()
+
+func loadNairobi() *time.Location {
+	loc, err := time.LoadLocation("Africa/Nairobi")
+	if err != nil {
+		//no tz database available, Kenya has no daylight saving
+		loc = time.FixedZone("EAT", 3*60*60)
+	}
+	return loc
+}
+
+//parseTime parses a daraja timestamp in the Africa/Nairobi timezone
+func parseTime(value, layout string) (t time.Time, err error) {
+	t, err = time.ParseInLocation(layout, strings.TrimSpace(value), nairobi)
+	if err != nil {
+		err = fmt.Errorf("Invalid timestamp %q", value)
+	}
+	return
+}
+
+//jsonNumber returns a json value decoded with UseNumber as json.Number,
+//numeric strings are accepted as daraja is not consistent with its types
+func jsonNumber(value interface{}) json.Number {
+	switch v := value.(type) {
+	case json.Number:
+		return v
+	case string:
+		return json.Number(strings.TrimSpace(v))
+	case float64:
+		//never in exponent form e.g. 1e+06
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return ""
+}
+
 // FormatPhoneNumber returns phone number is specific format (E164/National)
 func FormatPhoneNumber(phonenumber string, format string) (phone string, err error) {
 	num, err := phonenumbers.Parse(phonenumber, "KE")